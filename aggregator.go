@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/1729prashant/blog-aggregator/internal/config"
+	"github.com/1729prashant/blog-aggregator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAggConcurrency   = 4
+	defaultAggBatchSize     = 20
+	defaultAggLeaseDuration = 5 * time.Minute
+)
+
+// aggregator fetches feeds with a bounded pool of workers. Feeds are claimed
+// in the database with a lease before they are fetched, so that several agg
+// processes can share the work: a leased feed is skipped by the others until
+// its lease is released or, if its process died, expires. Within a process a
+// feed is handed to a worker only when no other worker has it.
+//
+// On shutdown the aggregator stops handing out feeds, gives back the leases
+// of the queued ones and waits for the workers to finish the feeds they are
+// fetching, unless it is told to cancel them.
+type aggregator struct {
+	s           *state
+	concurrency int
+	batchSize   int
+	allUsers    bool     // fetch the feeds followed by anyone
+	userNames   []string // otherwise fetch the feeds followed by these users
+	owner       string   // identifies this process in feed leases
+	lease       time.Duration
+	refresh     refreshPolicy
+	jobs        chan database.ClaimFeedsToFetchRow
+	workers     sync.WaitGroup
+	workCtx     context.Context // canceled to abort the fetches in progress
+	cancelWork  context.CancelFunc
+	stopping    atomic.Bool // set on shutdown, queued feeds are given back
+
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]bool
+	stats    aggregatorStats
+
+	outputMu sync.Mutex
+}
+
+// newAggregator starts the workers of an aggregator configured by the agg
+// section of the config file.
+func newAggregator(s *state, cfg config.AggConfig) (*aggregator, error) {
+	lease, err := parseConfigDuration(cfg.LeaseDuration, defaultAggLeaseDuration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agg lease_duration: %v", err)
+	}
+	if lease < time.Second {
+		return nil, fmt.Errorf("agg lease_duration %v is shorter than a second", lease)
+	}
+
+	minInterval, err := parseConfigDuration(cfg.MinRefreshInterval, defaultMinRefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agg min_refresh_interval: %v", err)
+	}
+	maxInterval, err := parseConfigDuration(cfg.MaxRefreshInterval, defaultMaxRefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agg max_refresh_interval: %v", err)
+	}
+	if minInterval > maxInterval {
+		return nil, fmt.Errorf("agg min_refresh_interval %v is above max_refresh_interval %v", minInterval, maxInterval)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultAggConcurrency
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultAggBatchSize
+	}
+
+	workCtx, cancelWork := context.WithCancel(context.Background())
+
+	userNames := cfg.Users
+	if len(userNames) == 0 {
+		userNames = []string{s.config.Name}
+	}
+
+	a := &aggregator{
+		s:           s,
+		concurrency: concurrency,
+		batchSize:   batchSize,
+		allUsers:    cfg.AllUsers,
+		userNames:   userNames,
+		owner:       fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), uuid.NewString()[:8]),
+		lease:       lease,
+		refresh:     refreshPolicy{MinInterval: minInterval, MaxInterval: maxInterval},
+		jobs:        make(chan database.ClaimFeedsToFetchRow, batchSize),
+		workCtx:     workCtx,
+		cancelWork:  cancelWork,
+		inFlight:    make(map[uuid.UUID]bool),
+	}
+	for range concurrency {
+		a.workers.Add(1)
+		go a.work()
+	}
+	return a, nil
+}
+
+// scope describes whose feeds the aggregator fetches.
+func (a *aggregator) scope() string {
+	if a.allUsers {
+		return "all users"
+	}
+	return strings.Join(a.userNames, ", ")
+}
+
+// claim marks a feed as taken by a worker. It reports false when the feed is
+// already queued or being fetched.
+func (a *aggregator) claim(feedID uuid.UUID) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.inFlight[feedID] {
+		return false
+	}
+	a.inFlight[feedID] = true
+	return true
+}
+
+func (a *aggregator) release(feedID uuid.UUID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.inFlight, feedID)
+}
+
+// busy returns the number of feeds queued or being fetched.
+func (a *aggregator) busy() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.inFlight)
+}
+
+// queue hands a claimed feed to the workers, waiting for room in the queue.
+func (a *aggregator) queue(feed database.ClaimFeedsToFetchRow) {
+	a.jobs <- feed
+}
+
+func (a *aggregator) work() {
+	defer a.workers.Done()
+	for feed := range a.jobs {
+		if a.stopping.Load() {
+			// Shutting down: leave the feed to the next run or to another
+			// process
+			if err := a.releaseLease(feed.ID); err != nil {
+				a.print(fmt.Sprintf("Error releasing feed lease: %v\n", err))
+			}
+			a.release(feed.ID)
+			continue
+		}
+
+		// Restart the lease now that the feed leaves the queue, in case it
+		// waited there for a while
+		held, err := a.extendLease(feed.ID)
+		if err != nil {
+			// Skip the feed rather than risk fetching it twice, it is claimed
+			// again on a later tick
+			a.print(fmt.Sprintf("Error extending the lease of feed %s: %v\n", feed.Name, err))
+			if err := a.releaseLease(feed.ID); err != nil {
+				a.print(fmt.Sprintf("Error releasing feed lease: %v\n", err))
+			}
+			a.release(feed.ID)
+			continue
+		}
+		if !held {
+			a.print(fmt.Sprintf("Skipping feed %s, another process took over its lease\n", feed.Name))
+			a.release(feed.ID)
+			continue
+		}
+
+		// Collect the output of a feed so that it isn't interleaved with
+		// the output of the other workers
+		var out bytes.Buffer
+		feedCtx, cancelFeed := context.WithCancel(a.workCtx)
+		stopRenewing := a.renewLease(feedCtx, feed.ID, cancelFeed)
+		result, err := scrapeFeed(feedCtx, a.s, &out, feed, a.refresh)
+		stopRenewing()
+		cancelFeed()
+		if err != nil {
+			fmt.Fprintf(&out, "Error scraping feed: %v\n", err)
+		}
+		a.record(result, err)
+		// Without the release the feed waits for its lease to expire
+		if err := a.releaseLease(feed.ID); err != nil {
+			fmt.Fprintf(&out, "Error releasing feed lease: %v\n", err)
+		}
+		a.release(feed.ID)
+		a.print(out.String())
+	}
+}
+
+// extendLease restarts the lease of a claimed feed. It reports false when
+// the lease is no longer held by this process.
+func (a *aggregator) extendLease(feedID uuid.UUID) (bool, error) {
+	rows, err := a.s.db.ExtendFeedLease(a.workCtx, database.ExtendFeedLeaseParams{
+		LeaseSeconds: int32(a.lease.Seconds()),
+		ID:           feedID,
+		Owner:        sql.NullString{String: a.owner, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// renewLease extends the lease of a feed every third of the lease duration
+// while it is being fetched, so that a long fetch isn't taken over by
+// another process. If the lease is lost anyway, lost is called to abort the
+// fetch. The returned function stops the renewals.
+func (a *aggregator) renewLease(ctx context.Context, feedID uuid.UUID, lost context.CancelFunc) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(a.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A failed renewal is retried on the next tick, the lease
+				// still has two thirds of its time left
+				held, err := a.extendLease(feedID)
+				if err == nil && !held {
+					lost()
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// releaseLease gives a claimed feed back to the other agg processes. It runs
+// even after the fetches were canceled, so that the feed isn't held up until
+// its lease expires.
+func (a *aggregator) releaseLease(feedID uuid.UUID) error {
+	return a.s.db.ReleaseFeedLease(context.WithoutCancel(a.workCtx), database.ReleaseFeedLeaseParams{
+		ID:         feedID,
+		LeaseOwner: sql.NullString{String: a.owner, Valid: true},
+	})
+}
+
+func (a *aggregator) print(output string) {
+	a.outputMu.Lock()
+	defer a.outputMu.Unlock()
+	os.Stdout.WriteString(output)
+}
+
+// aggregatorStats counts what an aggregator did, for the summary printed
+// when agg exits.
+type aggregatorStats struct {
+	Fetched      int
+	Failed       int
+	NewPosts     int
+	UpdatedPosts int
+}
+
+func (a *aggregator) record(result feedFetchResult, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Fetched++
+	if err != nil {
+		a.stats.Failed++
+	}
+	a.stats.NewPosts += result.NewPosts
+	a.stats.UpdatedPosts += result.UpdatedPosts
+}
+
+// summary returns what the aggregator did so far.
+func (a *aggregator) summary() aggregatorStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// stop makes the workers give back the feeds still in the queue instead of
+// fetching them. The feeds being fetched are finished.
+func (a *aggregator) stop() {
+	a.stopping.Store(true)
+}
+
+// cancel aborts the fetches in progress. Posts saved so far stay saved.
+func (a *aggregator) cancel() {
+	a.cancelWork()
+}
+
+// close stops handing out feeds and waits for the workers to finish the
+// feeds they have.
+func (a *aggregator) close() {
+	close(a.jobs)
+	a.workers.Wait()
+	a.cancelWork()
+}
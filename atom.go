@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type AtomFeed struct {
+	Base      string      `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Lang      string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title     AtomText    `xml:"title"`
+	Subtitle  AtomText    `xml:"subtitle"`
+	Links     []AtomLink  `xml:"link"`
+	Icon      string      `xml:"icon"`
+	Logo      string      `xml:"logo"`
+	Generator string      `xml:"generator"`
+	Entries   []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Base       string         `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	ID         string         `xml:"id"`
+	Title      AtomText       `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	Summary    AtomText       `xml:"summary"`
+	Content    AtomText       `xml:"content"`
+	Authors    []AtomPerson   `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// AtomText is an Atom text construct, which may hold plain text, escaped
+// HTML or inline XHTML depending on its type attribute.
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// String returns the markup of the text construct. Inline XHTML is kept as
+// raw inner XML so that its tags survive, everything else is character data.
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// HTML returns the text construct as HTML, escaping plain text so that it
+// isn't read as markup.
+func (t AtomText) HTML() string {
+	switch t.Type {
+	case "html", "xhtml":
+		return t.String()
+	default:
+		return textToHTML(t.String())
+	}
+}
+
+// parseAtomFeed decodes an Atom 1.0 document and maps it onto the RSSFeed
+// model so the rest of the program can treat every format the same way.
+func parseAtomFeed(body []byte) (*RSSFeed, error) {
+	var atom AtomFeed
+	err := xml.Unmarshal(body, &atom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse atom feed: %w", err)
+	}
+
+	var feed RSSFeed
+	feed.Base = atom.Base
+	feed.Channel.Title = atom.Title.String()
+	feed.Channel.Link = atomAlternateLink(atom.Links)
+	feed.Channel.Description = atom.Subtitle.String()
+	feed.Channel.Language = strings.TrimSpace(atom.Lang)
+	feed.Channel.Generator = strings.TrimSpace(atom.Generator)
+	feed.Channel.Image.URL = strings.TrimSpace(atom.Logo)
+	if feed.Channel.Image.URL == "" {
+		feed.Channel.Image.URL = strings.TrimSpace(atom.Icon)
+	}
+
+	for _, entry := range atom.Entries {
+		item := RSSItem{
+			Base:    entry.Base,
+			Title:   entry.Title.String(),
+			Link:    atomAlternateLink(entry.Links),
+			GUID:    RSSGUID{Value: strings.TrimSpace(entry.ID), IsPermaLink: "false"},
+			Content: entry.Content.HTML(),
+			Authors: atomAuthors(entry.Authors),
+		}
+
+		// Prefer the short summary for the description and fall back to the
+		// full content when the feed only publishes that.
+		item.Description = entry.Summary.HTML()
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" && strings.TrimSpace(link.Href) != "" {
+				item.Enclosures = append(item.Enclosures, RSSEnclosure{
+					URL:    strings.TrimSpace(link.Href),
+					Type:   link.Type,
+					Length: link.Length,
+				})
+			}
+		}
+
+		for _, category := range entry.Categories {
+			name := strings.TrimSpace(category.Label)
+			if name == "" {
+				name = strings.TrimSpace(category.Term)
+			}
+			if name != "" {
+				item.Categories = append(item.Categories, name)
+			}
+		}
+
+		item.PubDate = entry.Published
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
+
+// atomAlternateLink picks the link that points at the human readable page:
+// an HTML alternate link if there is one, then any alternate link, then the
+// first link with an href.
+func atomAlternateLink(links []AtomLink) string {
+	var alternate, first string
+	for _, link := range links {
+		if link.Href == "" {
+			continue
+		}
+		if first == "" {
+			first = link.Href
+		}
+		// A missing rel attribute means "alternate" per RFC 4287.
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" || link.Type == "application/xhtml+xml" {
+			return link.Href
+		}
+		if alternate == "" {
+			alternate = link.Href
+		}
+	}
+	if alternate != "" {
+		return alternate
+	}
+	return first
+}
+
+// atomAuthors returns the names of all authors of an entry, using the email
+// address for authors that only publish that.
+func atomAuthors(authors []AtomPerson) []string {
+	var names []string
+	for _, author := range authors {
+		name := strings.TrimSpace(author.Name)
+		if name == "" {
+			name = strings.TrimSpace(author.Email)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
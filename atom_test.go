@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestDecodeAtomFeed(t *testing.T) {
+	feed := decodeTestFeed(t, "atom.xml", "application/atom+xml")
+
+	if feed.Channel.Title != "Example Atom" {
+		t.Errorf("channel title = %q, want %q", feed.Channel.Title, "Example Atom")
+	}
+	if feed.Channel.Link != "https://example.com/" {
+		t.Errorf("channel link = %q, want %q", feed.Channel.Link, "https://example.com/")
+	}
+	if feed.Channel.Description != "Atom posts" {
+		t.Errorf("channel description = %q, want %q", feed.Channel.Description, "Atom posts")
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+
+	checkFeedItem(t, feed.Channel.Item[0], RSSItem{
+		Title:       "Comparing a < b",
+		Link:        "https://example.com/posts/compare",
+		GUID:        RSSGUID{Value: "tag:example.com,2024:1"},
+		Description: "<p>a &lt; b<br>c</p>",
+		Content:     "<pre>&lt;b&gt;</pre>",
+		PubDate:     "2024-09-02T10:30:00Z",
+		Authors:     []string{"John Roe"},
+		Categories:  []string{"math"},
+		Enclosures:  []RSSEnclosure{{URL: "https://example.com/compare.mp3", Type: "audio/mpeg", Length: "5678"}},
+	})
+}
+
+func TestAtomTextHTML(t *testing.T) {
+	tests := []struct {
+		text AtomText
+		want string
+	}{
+		{AtomText{Text: "a < b"}, "<p>a &lt; b</p>"},
+		{AtomText{Type: "text", Text: "a & b"}, "<p>a &amp; b</p>"},
+		{AtomText{Type: "html", Text: " <p>a &amp; b</p> "}, "<p>a &amp; b</p>"},
+		{AtomText{Type: "xhtml", Text: "ignored", InnerXML: "<div><p>a</p></div>"}, "<div><p>a</p></div>"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.text.HTML(); got != tt.want {
+			t.Errorf("%+v.HTML() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// xmlDeclEncoding matches the encoding pseudo-attribute of an XML declaration.
+var xmlDeclEncoding = regexp.MustCompile(`^(\s*<\?xml[^>]*?\sencoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// toUTF8 transcodes a fetched document to UTF-8. The charset comes from the
+// Content-Type header, which takes precedence as in RFC 7303, then from a
+// byte order mark and finally from the XML declaration. The XML declaration
+// is always rewritten to say UTF-8 so that encoding/xml does not try to
+// convert the document a second time.
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	label := contentTypeCharset(contentType)
+	if label == "" {
+		switch {
+		case bytes.HasPrefix(body, utf8BOM):
+			label = "utf-8"
+		case bytes.HasPrefix(body, utf16BEBOM):
+			label = "utf-16be"
+		case bytes.HasPrefix(body, utf16LEBOM):
+			label = "utf-16le"
+		default:
+			if match := xmlDeclEncoding.FindSubmatch(body); match != nil {
+				label = string(match[2])
+			}
+		}
+	}
+
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "utf-16" && bytes.HasPrefix(body, utf16BEBOM) {
+		// charset.Lookup reads plain "utf-16" as little-endian and ignores
+		// the byte order mark
+		label = "utf-16be"
+	}
+	if label == "" || label == "utf-8" || label == "utf8" {
+		return xmlDeclEncoding.ReplaceAll(bytes.TrimPrefix(body, utf8BOM), []byte("${1}UTF-8${3}")), nil
+	}
+
+	encoding, name := charset.Lookup(label)
+	if encoding == nil {
+		return nil, fmt.Errorf("unsupported charset: %s", label)
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s body: %w", name, err)
+	}
+	decoded = bytes.TrimPrefix(decoded, utf8BOM)
+
+	return xmlDeclEncoding.ReplaceAll(decoded, []byte("${1}UTF-8${3}")), nil
+}
+
+// contentTypeCharset returns the charset parameter of a Content-Type header.
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
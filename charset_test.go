@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestToUTF8(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="%s"?><rss><channel><title>Café – naïve</title></channel></rss>`
+	const want = `<?xml version="1.0" encoding="UTF-8"?><rss><channel><title>Café – naïve</title></channel></rss>`
+
+	encode := func(enc encoding.Encoding, declared string) []byte {
+		body, err := enc.NewEncoder().String(strings.Replace(doc, "%s", declared, 1))
+		if err != nil {
+			t.Fatalf("failed to encode test document: %v", err)
+		}
+		return []byte(body)
+	}
+	utf16BE := unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	utf16LE := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+	}{
+		{"utf-8", []byte(strings.Replace(doc, "%s", "UTF-8", 1)), "application/rss+xml"},
+		{"utf-8 with bom", append([]byte{0xEF, 0xBB, 0xBF}, strings.Replace(doc, "%s", "UTF-8", 1)...), ""},
+		{"windows-1252 from the xml declaration", encode(charmap.Windows1252, "windows-1252"), "application/xml"},
+		{"windows-1252 from the content type", encode(charmap.Windows1252, "UTF-8"), "text/xml; charset=windows-1252"},
+		{"utf-16 big-endian bom", encode(utf16BE, "UTF-16"), ""},
+		{"utf-16 little-endian bom", encode(utf16LE, "UTF-16"), ""},
+		{"utf-16 big-endian with a utf-16 content type", encode(utf16BE, "UTF-16"), "application/xml; charset=utf-16"},
+		{"utf-16 little-endian with a utf-16 content type", encode(utf16LE, "UTF-16"), "application/xml; charset=utf-16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toUTF8(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("toUTF8 returned error: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("toUTF8 = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, err := toUTF8([]byte("<rss/>"), "text/xml; charset=x-unknown"); err == nil {
+		t.Error("toUTF8 with an unknown charset returned no error")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the MIME types advertised by <link rel="alternate"> tags
+// that point at a feed we know how to parse. Plain application/json is left
+// out: WordPress uses it to advertise its REST API on every page.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// commonFeedPaths are tried in order, relative to the site root, when a page
+// does not advertise any feed. Sites often serve the same feed under several
+// of them, so the first one that works is taken.
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/feed.json",
+}
+
+type discoveredFeed struct {
+	URL   string
+	Title string
+	Type  string
+	Feed  *RSSFeed // the parsed feed, when it was already fetched
+}
+
+// discoverFeed resolves the URL given to addfeed to a feed URL and returns
+// the parsed feed. Feed URLs are kept as they are; for HTML pages the
+// advertised feeds are looked up, falling back to a handful of well known
+// paths, and the user is asked to pick one when there are several.
+func discoverFeed(ctx context.Context, f *fetcher, pageURL string) (string, *RSSFeed, error) {
+	res, err := f.fetchURL(ctx, pageURL, cacheValidators{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !isHTMLPage(res.ContentType, res.Body) {
+		rssFeed, err := decodeFeed(res)
+		if err != nil {
+			return "", nil, fmt.Errorf("not a valid feed: %w", err)
+		}
+		return pageURL, rssFeed, nil
+	}
+
+	candidates, err := findFeedLinks(res.Body, res.URL)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(candidates) == 0 {
+		candidates = probeCommonFeedPaths(ctx, f, res.URL)
+	}
+
+	var chosen discoveredFeed
+	switch len(candidates) {
+	case 0:
+		return "", nil, fmt.Errorf("no feed found at '%s'", pageURL)
+	case 1:
+		chosen = candidates[0]
+		fmt.Printf("Found feed '%s'.\n", chosen.URL)
+	default:
+		chosen, err = promptFeedChoice(candidates)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if chosen.Feed != nil {
+		return chosen.URL, chosen.Feed, nil
+	}
+	rssFeed, err := f.fetchFeed(ctx, chosen.URL)
+	if err != nil {
+		return "", nil, fmt.Errorf("feed '%s' is not a valid feed: %w", chosen.URL, err)
+	}
+	return chosen.URL, rssFeed, nil
+}
+
+// isHTMLPage reports whether a response is a web page rather than a feed.
+func isHTMLPage(contentType string, body []byte) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "text/html") || strings.Contains(contentType, "application/xhtml+xml") {
+		return true
+	}
+	if contentType != "" && !strings.HasPrefix(contentType, "text/plain") {
+		return false
+	}
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// findFeedLinks returns the feeds advertised with <link rel="alternate"> in
+// an HTML page, resolved against the page URL (or its <base href>).
+func findFeedLinks(body []byte, pageURL string) ([]discoveredFeed, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page url: %w", err)
+	}
+
+	var feeds []discoveredFeed
+	seen := make(map[string]bool)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "base":
+				if href := htmlAttr(n, "href"); href != "" {
+					if resolved, err := base.Parse(href); err == nil {
+						base = resolved
+					}
+				}
+			case "link":
+				rels := strings.Fields(strings.ToLower(htmlAttr(n, "rel")))
+				linkType := strings.ToLower(strings.TrimSpace(htmlAttr(n, "type")))
+				href := strings.TrimSpace(htmlAttr(n, "href"))
+				if href != "" && slices.Contains(rels, "alternate") && feedLinkTypes[linkType] {
+					if resolved, err := base.Parse(href); err == nil && !seen[resolved.String()] {
+						seen[resolved.String()] = true
+						feeds = append(feeds, discoveredFeed{
+							URL:   resolved.String(),
+							Title: strings.TrimSpace(htmlAttr(n, "title")),
+							Type:  linkType,
+						})
+					}
+				}
+			case "body":
+				// Feed links live in <head>, no need to walk the whole page.
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return feeds, nil
+}
+
+// probeCommonFeedPaths tries the well known feed locations of a site and
+// returns the first one that parses as a feed, if any.
+func probeCommonFeedPaths(ctx context.Context, f *fetcher, pageURL string) []discoveredFeed {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	for _, path := range commonFeedPaths {
+		candidate := base.ResolveReference(&url.URL{Path: path}).String()
+		feed, err := f.fetchFeed(ctx, candidate)
+		if err != nil {
+			continue
+		}
+		return []discoveredFeed{{
+			URL:   candidate,
+			Title: feed.Channel.Title,
+			Feed:  feed,
+		}}
+	}
+	return nil
+}
+
+// promptFeedChoice lists the discovered feeds and reads the user's pick from stdin.
+func promptFeedChoice(candidates []discoveredFeed) (discoveredFeed, error) {
+	fmt.Println("Several feeds were found:")
+	for i, candidate := range candidates {
+		label := candidate.Title
+		if label == "" {
+			label = candidate.URL
+		}
+		if candidate.Type != "" {
+			fmt.Printf("  %d) %s <%s> (%s)\n", i+1, label, candidate.URL, candidate.Type)
+		} else {
+			fmt.Printf("  %d) %s <%s>\n", i+1, label, candidate.URL)
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Choose a feed [1-%d]: ", len(candidates))
+		line, err := reader.ReadString('\n')
+		choice, convErr := strconv.Atoi(strings.TrimSpace(line))
+		if convErr == nil && choice >= 1 && choice <= len(candidates) {
+			return candidates[choice-1], nil
+		}
+		if err != nil {
+			return discoveredFeed{}, fmt.Errorf("no feed selected")
+		}
+		fmt.Println("Invalid choice.")
+	}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindFeedLinks(t *testing.T) {
+	page := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <base href="https://example.com/blog/">
+  <link rel="alternate" type="application/rss+xml" title="Posts" href="feed/">
+  <link rel="alternate" type="application/rss+xml" title="Posts again" href="https://example.com/blog/feed/">
+  <link rel="alternate" type="application/atom+xml" href="/atom.xml">
+  <link rel="alternate" type="application/json" href="https://example.com/wp-json/wp/v2/pages/2">
+  <link rel="alternate" type="application/feed+json" href="feed.json">
+  <link rel="stylesheet" type="text/css" href="style.css">
+</head>
+<body>
+  <link rel="alternate" type="application/rss+xml" href="/ignored.xml">
+</body>
+</html>`)
+
+	feeds, err := findFeedLinks(page, "https://example.com/blog/post")
+	if err != nil {
+		t.Fatalf("findFeedLinks returned error: %v", err)
+	}
+
+	var urls []string
+	for _, feed := range feeds {
+		urls = append(urls, feed.URL)
+	}
+	want := []string{
+		"https://example.com/blog/feed/",
+		"https://example.com/atom.xml",
+		"https://example.com/blog/feed.json",
+	}
+	if !slices.Equal(urls, want) {
+		t.Errorf("findFeedLinks = %q, want %q", urls, want)
+	}
+	if len(feeds) > 0 && feeds[0].Title != "Posts" {
+		t.Errorf("first feed title = %q, want %q", feeds[0].Title, "Posts")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hasEnclosure reports whether enclosures already contain enclosureURL.
+func hasEnclosure(enclosures []RSSEnclosure, enclosureURL string) bool {
+	for _, enclosure := range enclosures {
+		if strings.TrimSpace(enclosure.URL) == strings.TrimSpace(enclosureURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMediaDuration parses itunes:duration and media:content durations,
+// which come as plain seconds, "MM:SS" or "HH:MM:SS". It returns 0 when the
+// value can't be read.
+func parseMediaDuration(value string) int64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return int64(seconds)
+}
+
+// formatDuration prints seconds as H:MM:SS or M:SS.
+func formatDuration(seconds int64) string {
+	h, m, sec := seconds/3600, seconds%3600/60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
+// formatBytes prints a size in bytes in the largest fitting binary unit.
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// enclosureFileName builds a file name for a downloaded enclosure from the
+// last segment of its URL, prefixed with a short hash of the full URL so
+// that episodes that all end in e.g. "audio.mp3" don't overwrite each other.
+func enclosureFileName(enclosureURL string) string {
+	sum := sha256.Sum256([]byte(enclosureURL))
+	prefix := hex.EncodeToString(sum[:4])
+
+	name := ""
+	if parsed, err := url.Parse(enclosureURL); err == nil {
+		name = path.Base(parsed.Path)
+	}
+	name = safeFileName(name)
+	if name == "_" {
+		name = "enclosure"
+	}
+	return prefix + "-" + name
+}
+
+// safeFileName replaces characters that are awkward in file names. Names
+// that are empty or refer to a directory ("." and "..") become "_", so the
+// result is always a single path element.
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 32, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// enclosurePath returns where the enclosure of a feed is downloaded to under
+// dir: a folder named after the feed holding the enclosure's file. Feed
+// names come from the feeds themselves, so the result is checked to stay
+// inside dir.
+func enclosurePath(dir, feedName, enclosureURL string) (string, error) {
+	rel := filepath.Join(safeFileName(feedName), enclosureFileName(enclosureURL))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("unsafe download path %q", rel)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// downloadEnclosure downloads an enclosure to target. Data is written to a
+// ".part" file first, and an existing partial download is resumed with a
+// Range request when the server supports it. It reports whether anything
+// was downloaded, as a complete file is left alone.
+func downloadEnclosure(ctx context.Context, f *fetcher, enclosureURL, target string) (bool, error) {
+	if _, err := os.Stat(target); err == nil {
+		return false, nil
+	}
+
+	partial := target + ".part"
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", enclosureURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("User-Agent", f.userAgent)
+	if offset > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := f.downloadClient().Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to download: %w", err)
+	}
+	defer res.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file already holds the whole enclosure
+		return true, os.Rename(partial, target)
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		// The server ignored the Range header, start over
+		flags |= os.O_TRUNC
+	default:
+		return false, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return false, fmt.Errorf("error creating directory: %v", err)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return false, fmt.Errorf("error opening file: %v", err)
+	}
+
+	_, err = io.Copy(file, res.Body)
+	closeErr := file.Close()
+	if err != nil {
+		return false, fmt.Errorf("download interrupted, run the command again to resume: %w", err)
+	}
+	if closeErr != nil {
+		return false, fmt.Errorf("error saving file: %v", closeErr)
+	}
+
+	return true, os.Rename(partial, target)
+}
+
+// enclosureSummary describes an enclosure on one line for browse.
+func enclosureSummary(mimeType string, length, duration int64) string {
+	var details []string
+	if mimeType != "" {
+		details = append(details, mimeType)
+	}
+	if length > 0 {
+		details = append(details, formatBytes(length))
+	}
+	if duration > 0 {
+		details = append(details, formatDuration(duration))
+	}
+	return strings.Join(details, ", ")
+}
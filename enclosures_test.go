@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnclosurePath(t *testing.T) {
+	const enclosureURL = "https://example.com/episodes/a.mp3"
+	fileName := enclosureFileName(enclosureURL)
+
+	tests := []struct {
+		feedName string
+		wantDir  string
+	}{
+		{"My Podcast", "My Podcast"},
+		{"", "_"},
+		{".", "_"},
+		{"..", "_"},
+		{" .. ", "_"},
+		{"../..", ".._.."},
+		{"a/b", "a_b"},
+		{`C:\evil`, "C__evil"},
+	}
+
+	for _, tt := range tests {
+		got, err := enclosurePath("dl", tt.feedName, enclosureURL)
+		if err != nil {
+			t.Errorf("enclosurePath(%q) returned error: %v", tt.feedName, err)
+			continue
+		}
+		want := filepath.Join("dl", tt.wantDir, fileName)
+		if got != want {
+			t.Errorf("enclosurePath(%q) = %q, want %q", tt.feedName, got, want)
+		}
+		rel, err := filepath.Rel("dl", got)
+		if err != nil || !filepath.IsLocal(rel) {
+			t.Errorf("enclosurePath(%q) = %q is outside the download directory", tt.feedName, got)
+		}
+	}
+}
+
+func TestEnclosureFileName(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantName string
+	}{
+		{"https://example.com/episodes/a.mp3", "a.mp3"},
+		{"https://example.com/", "enclosure"},
+		{"https://example.com/..", "enclosure"},
+		{"https://example.com/a%2F..%2Fb.mp3", "b.mp3"},
+	}
+
+	for _, tt := range tests {
+		got := enclosureFileName(tt.url)
+		prefix, name, ok := strings.Cut(got, "-")
+		if !ok || len(prefix) != 8 || name != tt.wantName {
+			t.Errorf("enclosureFileName(%q) = %q, want <hash>-%s", tt.url, got, tt.wantName)
+		}
+	}
+
+	if enclosureFileName("https://a.example/audio.mp3") == enclosureFileName("https://b.example/audio.mp3") {
+		t.Error("enclosures with the same file name in different places got the same file name")
+	}
+}
+
+func TestParseMediaDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"", 0},
+		{"90", 90},
+		{"1:30", 90},
+		{"01:02:03", 3723},
+		{"12.5", 12},
+		{"abc", 0},
+		{"-5", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseMediaDuration(tt.input); got != tt.want {
+			t.Errorf("parseMediaDuration(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
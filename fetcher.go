@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1729prashant/blog-aggregator/internal/config"
+)
+
+const (
+	defaultFetchTimeout        = 30 * time.Second
+	defaultConnectTimeout      = 10 * time.Second
+	defaultMaxBodyBytes        = 10 << 20
+	defaultUserAgent           = "gator"
+	defaultMaxIdleConnsPerHost = 4
+)
+
+// fetcher is the HTTP client shared by everything that downloads feeds, web
+// pages and enclosures, so that connections are reused between requests.
+type fetcher struct {
+	client       *http.Client
+	transport    *http.Transport
+	userAgent    string
+	maxBodyBytes int64
+}
+
+// fetchResponse is the part of an HTTP response the feed code cares about.
+type fetchResponse struct {
+	StatusCode   int
+	Body         []byte
+	ContentType  string
+	URL          string // final URL after following redirects
+	ETag         string
+	LastModified string
+	NotModified  bool // the server answered 304, Body is empty
+	// PermanentURL is where the requested URL has permanently moved to, when
+	// the request went through 301 or 308 redirects
+	PermanentURL string
+	// CacheLifetime is how long the server says the response stays fresh,
+	// from Cache-Control or Expires
+	CacheLifetime time.Duration
+}
+
+// httpStatusError is returned for responses with an unexpected status code.
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// cacheValidators are the ETag and Last-Modified values of an earlier
+// response, sent back so the server can answer 304 Not Modified.
+type cacheValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// newFetcher builds a fetcher from the fetch section of the config file.
+func newFetcher(cfg config.FetchConfig) (*fetcher, error) {
+	timeout, err := parseConfigDuration(cfg.Timeout, defaultFetchTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fetch timeout: %v", err)
+	}
+	connectTimeout, err := parseConfigDuration(cfg.ConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fetch connect_timeout: %v", err)
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch proxy: %v", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported fetch proxy scheme: %s", proxyURL.Scheme)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.CABundle != "" {
+		pool, err := loadCABundle(cfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	maxIdleConnsPerHost := cfg.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: timeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	return &fetcher{
+		client:       &http.Client{Transport: transport, Timeout: timeout},
+		transport:    transport,
+		userAgent:    userAgent,
+		maxBodyBytes: maxBodyBytes,
+	}, nil
+}
+
+// parseConfigDuration parses a duration from the config file, returning
+// fallback when it is not set.
+func parseConfigDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %s", value)
+	}
+	return d, nil
+}
+
+// loadCABundle returns the system certificate pool with the certificates of
+// a PEM file added to it.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading ca_bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in ca_bundle %s", path)
+	}
+	return pool, nil
+}
+
+// fetchURL performs a GET request for a feed or web page and returns its body.
+// When validators are given the request is conditional, and a 304 response is
+// returned with NotModified set instead of an error.
+func (f *fetcher) fetchURL(ctx context.Context, targetURL string, validators cacheValidators) (*fetchResponse, error) {
+	// Create a new HTTP request with context
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set the User-Agent header
+	req.Header.Add("User-Agent", f.userAgent)
+	req.Header.Add("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/json;q=0.9, application/xml;q=0.9, */*;q=0.8")
+	if validators.ETag != "" {
+		req.Header.Add("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Add("If-Modified-Since", validators.LastModified)
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch RSS: %w", err)
+	}
+	defer res.Body.Close()
+
+	response := &fetchResponse{
+		StatusCode:    res.StatusCode,
+		ContentType:   res.Header.Get("Content-Type"),
+		URL:           res.Request.URL.String(),
+		ETag:          res.Header.Get("ETag"),
+		LastModified:  res.Header.Get("Last-Modified"),
+		PermanentURL:  permanentRedirectURL(res),
+		CacheLifetime: cacheLifetime(res.Header, time.Now()),
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		// A 304 may leave out the validators, keep the ones we sent
+		if response.ETag == "" {
+			response.ETag = validators.ETag
+		}
+		if response.LastModified == "" {
+			response.LastModified = validators.LastModified
+		}
+		response.NotModified = true
+		return response, nil
+	}
+
+	// Check for non-success HTTP status codes
+	if res.StatusCode >= 300 {
+		return nil, &httpStatusError{StatusCode: res.StatusCode}
+	}
+
+	if res.ContentLength > f.maxBodyBytes {
+		return nil, fmt.Errorf("response body too large: %d bytes (limit %d)", res.ContentLength, f.maxBodyBytes)
+	}
+
+	// Read the response body, one byte past the limit to notice oversized bodies
+	response.Body, err = io.ReadAll(io.LimitReader(res.Body, f.maxBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(response.Body)) > f.maxBodyBytes {
+		return nil, fmt.Errorf("response body too large: over %d bytes", f.maxBodyBytes)
+	}
+
+	return response, nil
+}
+
+// cacheLifetime returns how long a response may be cached according to its
+// Cache-Control max-age, or else its Expires header. It returns 0 when the
+// response is not cacheable or says nothing about it.
+func cacheLifetime(header http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+			return 0
+		}
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	// Measure from the server's clock when it sent one
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	return max(expires.Sub(now), 0)
+}
+
+// permanentRedirectURL returns the URL reached by the permanent redirects at
+// the start of the redirect chain of a response. A temporary redirect ends
+// the chain, as whatever follows it may change again.
+func permanentRedirectURL(res *http.Response) string {
+	// Each request after a redirect points at the response that caused it
+	var hops []*http.Request
+	for req := res.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		hops = append(hops, req)
+	}
+
+	permanentURL := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		switch hops[i].Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+			permanentURL = hops[i].URL.String()
+		default:
+			return permanentURL
+		}
+	}
+	return permanentURL
+}
+
+func (f *fetcher) fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	res, err := f.fetchURL(ctx, feedURL, cacheValidators{})
+	if err != nil {
+		return nil, err
+	}
+	return decodeFeed(res)
+}
+
+// downloadClient returns a client for large downloads such as enclosures.
+// It shares the connections, proxy and TLS settings of the fetcher but has
+// no overall timeout, as a long episode can take a while to download.
+func (f *fetcher) downloadClient() *http.Client {
+	return &http.Client{Transport: f.transport}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPermanentRedirectURL(t *testing.T) {
+	mux := http.NewServeMux()
+	redirect := func(from, to string, code int) {
+		mux.HandleFunc(from, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, to, code)
+		})
+	}
+	redirect("/moved", "/feed.xml", http.StatusMovedPermanently)
+	redirect("/moved-twice", "/moved-again", http.StatusMovedPermanently)
+	redirect("/moved-again", "/feed.xml", http.StatusPermanentRedirect)
+	redirect("/temporary", "/feed.xml", http.StatusFound)
+	redirect("/moved-then-temporary", "/temporary-hop", http.StatusMovedPermanently)
+	redirect("/temporary-hop", "/feed.xml", http.StatusTemporaryRedirect)
+	redirect("/temporary-then-moved", "/moved", http.StatusFound)
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss/>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/feed.xml", ""},
+		{"/moved", "/feed.xml"},
+		{"/moved-twice", "/feed.xml"},
+		{"/temporary", ""},
+		{"/moved-then-temporary", "/temporary-hop"},
+		{"/temporary-then-moved", ""},
+	}
+
+	for _, tt := range tests {
+		res, err := server.Client().Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		res.Body.Close()
+
+		want := tt.want
+		if want != "" {
+			want = server.URL + want
+		}
+		if got := permanentRedirectURL(res); got != want {
+			t.Errorf("permanentRedirectURL for %s = %q, want %q", tt.path, got, want)
+		}
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	now := time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"no headers", http.Header{}, 0},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=3600"}}, time.Hour},
+		{"quoted max-age", http.Header{"Cache-Control": {`max-age="600"`}}, 10 * time.Minute},
+		{"max-age zero", http.Header{"Cache-Control": {"max-age=0"}}, 0},
+		{"invalid max-age", http.Header{"Cache-Control": {"max-age=soon"}}, 0},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=3600"}}, 0},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, 0},
+		{
+			"max-age wins over expires",
+			http.Header{"Cache-Control": {"max-age=60"}, "Expires": {"Mon, 02 Sep 2024 14:00:00 GMT"}},
+			time.Minute,
+		},
+		{"expires", http.Header{"Expires": {"Mon, 02 Sep 2024 14:00:00 GMT"}}, 2 * time.Hour},
+		{"expires in the past", http.Header{"Expires": {"Mon, 02 Sep 2024 10:00:00 GMT"}}, 0},
+		{"invalid expires", http.Header{"Expires": {"0"}}, 0},
+		{
+			"expires from the server date",
+			http.Header{"Expires": {"Mon, 02 Sep 2024 14:00:00 GMT"}, "Date": {"Mon, 02 Sep 2024 13:30:00 GMT"}},
+			30 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := cacheLifetime(tt.header, now); got != tt.want {
+			t.Errorf("%s: cacheLifetime = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
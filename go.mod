@@ -3,6 +3,10 @@ module github.com/1729prashant/blog-aggregator
 go 1.23.3
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
 )
+
+require golang.org/x/net v0.33.0
+
+require golang.org/x/text v0.21.0
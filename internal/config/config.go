@@ -11,8 +11,54 @@ const configFileName = ".gatorconfig.json"
 
 // Config struct represents the JSON file structure.
 type Config struct {
-	DbURL string `json:"db_url"`
-	Name  string `json:"current_user_name"`
+	DbURL string      `json:"db_url"`
+	Name  string      `json:"current_user_name"`
+	Fetch FetchConfig `json:"fetch"`
+	Agg   AggConfig   `json:"agg"`
+}
+
+// AggConfig controls how the agg command schedules feed fetches. Zero values
+// fall back to the defaults of the aggregator.
+type AggConfig struct {
+	// Concurrency is the number of feeds fetched at the same time
+	Concurrency int `json:"concurrency,omitempty"`
+	// BatchSize is the largest number of due feeds queued on every tick
+	BatchSize int `json:"batch_size,omitempty"`
+	// LeaseDuration is how long a claimed feed stays reserved for this
+	// process, e.g. "5m". Leases of crashed processes expire after it.
+	LeaseDuration string `json:"lease_duration,omitempty"`
+	// MinRefreshInterval and MaxRefreshInterval bound how often a feed is
+	// fetched, e.g. "15m" and "24h". Within them the interval adapts to
+	// how often the feed publishes.
+	MinRefreshInterval string `json:"min_refresh_interval,omitempty"`
+	MaxRefreshInterval string `json:"max_refresh_interval,omitempty"`
+	// AllUsers fetches every feed followed by anyone, for a single agg
+	// serving all users
+	AllUsers bool `json:"all_users,omitempty"`
+	// Users fetches the feeds followed by these users. When neither
+	// AllUsers nor Users is set, the feeds of the current user are fetched.
+	Users []string `json:"users,omitempty"`
+}
+
+// FetchConfig controls the HTTP client used to fetch feeds. Zero values fall
+// back to the defaults of the fetcher.
+type FetchConfig struct {
+	// Timeout limits a whole request, including reading the body, e.g. "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// ConnectTimeout limits dialing and the TLS handshake, e.g. "10s"
+	ConnectTimeout string `json:"connect_timeout,omitempty"`
+	// MaxBodyBytes is the largest response body that is read
+	MaxBodyBytes int64  `json:"max_body_bytes,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	// Proxy is an http://, https:// or socks5:// URL. When empty the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+	Proxy string `json:"proxy,omitempty"`
+	// CABundle is a PEM file of certificates trusted in addition to the
+	// system roots
+	CABundle string `json:"ca_bundle,omitempty"`
+	// MaxIdleConnsPerHost is the number of keep-alive connections kept open
+	// to each host
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host,omitempty"`
 }
 
 // getConfigFilePath returns the full path to the config file.
@@ -113,7 +113,7 @@ FROM feed_follows ff, feeds f, users u
 WHERE ff.feed_id = f.id 
 AND ff.user_id = u.id 
 AND u.name = $1
-AND f.url = $2
+AND (f.url = $2 OR f.id IN (SELECT fu.feed_id FROM feed_urls fu WHERE fu.url = $2))
 `
 
 type GetFeedIDUserIDfromFollowsParams struct {
@@ -132,22 +132,3 @@ func (q *Queries) GetFeedIDUserIDfromFollows(ctx context.Context, arg GetFeedIDU
 	err := row.Scan(&i.FeedID, &i.UserID)
 	return i, err
 }
-
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-
-
-SELECT f.url
-FROM feed_follows ff, feeds f, users u 
-WHERE ff.feed_id = f.id 
-AND ff.user_id = u.id 
-AND u.name = $1
-ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1
-`
-
-func (q *Queries) GetNextFeedToFetch(ctx context.Context, name string) (string, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, name)
-	var url string
-	err := row.Scan(&url)
-	return url, err
-}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const addFeed = `-- name: AddFeed :one
@@ -24,7 +25,7 @@ VALUES (
     $6,
     $7
 )
-RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, site_link, description, language, image_url, generator, etag, last_modified, active, consecutive_failures, last_error, next_attempt_at, lease_owner, lease_expires_at, ttl_minutes, skip_hours, skip_days, update_period, update_frequency
 `
 
 type AddFeedParams struct {
@@ -56,23 +57,196 @@ func (q *Queries) AddFeed(ctx context.Context, arg AddFeedParams) (Feed, error)
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.Etag,
+		&i.LastModified,
+		&i.Active,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
 	)
 	return i, err
 }
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+
+
+UPDATE feeds
+SET lease_owner = $1,
+    lease_expires_at = LOCALTIMESTAMP + $2::INTEGER * INTERVAL '1 second'
+WHERE id IN (
+    SELECT f.id
+    FROM feeds f
+    WHERE f.active
+    AND (f.next_attempt_at IS NULL OR f.next_attempt_at <= LOCALTIMESTAMP)
+    AND (f.lease_expires_at IS NULL OR f.lease_expires_at <= LOCALTIMESTAMP)
+    AND EXISTS (
+        SELECT 1
+        FROM feed_follows ff
+        JOIN users u ON ff.user_id = u.id
+        WHERE ff.feed_id = f.id
+        AND ($3::BOOLEAN OR u.name = ANY($4::TEXT[]))
+    )
+    ORDER BY f.last_fetched_at NULLS FIRST
+    LIMIT $5
+    FOR UPDATE OF f SKIP LOCKED
+)
+RETURNING id, name, url
+`
+
+type ClaimFeedsToFetchParams struct {
+	Owner        sql.NullString
+	LeaseSeconds int32
+	AllUsers     bool
+	UserNames    []string
+	MaxFeeds     int32
+}
+
+type ClaimFeedsToFetchRow struct {
+	ID   uuid.UUID
+	Name string
+	Url  string
+}
+
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]ClaimFeedsToFetchRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch,
+		arg.Owner,
+		arg.LeaseSeconds,
+		arg.AllUsers,
+		pq.Array(arg.UserNames),
+		arg.MaxFeeds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimFeedsToFetchRow
+	for rows.Next() {
+		var i ClaimFeedsToFetchRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createFeedURL = `-- name: CreateFeedURL :exec
+
+
+INSERT INTO feed_urls (id, created_at, feed_id, url)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (url) DO NOTHING
+`
+
+type CreateFeedURLParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Url       string
+}
+
+func (q *Queries) CreateFeedURL(ctx context.Context, arg CreateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedURL,
+		arg.ID,
+		arg.CreatedAt,
+		arg.FeedID,
+		arg.Url,
+	)
+	return err
+}
+
+const deactivateFeed = `-- name: DeactivateFeed :exec
+
+
+UPDATE feeds SET active = FALSE, updated_at = $1
+WHERE id = $2
+`
+
+type DeactivateFeedParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) DeactivateFeed(ctx context.Context, arg DeactivateFeedParams) error {
+	_, err := q.db.ExecContext(ctx, deactivateFeed, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const extendFeedLease = `-- name: ExtendFeedLease :execrows
+
+
+UPDATE feeds
+SET lease_expires_at = LOCALTIMESTAMP + $1::INTEGER * INTERVAL '1 second'
+WHERE id = $2 AND lease_owner = $3
+`
+
+type ExtendFeedLeaseParams struct {
+	LeaseSeconds int32
+	ID           uuid.UUID
+	Owner        sql.NullString
+}
+
+func (q *Queries) ExtendFeedLease(ctx context.Context, arg ExtendFeedLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, extendFeedLease, arg.LeaseSeconds, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const feedNameExists = `-- name: FeedNameExists :one
+
+
+SELECT EXISTS (SELECT 1 FROM feeds WHERE name = $1)
+`
+
+func (q *Queries) FeedNameExists(ctx context.Context, name string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, feedNameExists, name)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const getAllFeeds = `-- name: GetAllFeeds :many
 
 
-SELECT f.name, f.url, u.name
+SELECT f.name, f.url, u.name, f.site_link, f.description, f.language, f.image_url, f.generator, f.active,
+    f.consecutive_failures, f.last_error
 FROM feeds f, users u
 where u.id = f.user_id
 ORDER BY f.name
 `
 
 type GetAllFeedsRow struct {
-	Name   string
-	Url    string
-	Name_2 string
+	Name                string
+	Url                 string
+	Name_2              string
+	SiteLink            sql.NullString
+	Description         sql.NullString
+	Language            sql.NullString
+	ImageUrl            sql.NullString
+	Generator           sql.NullString
+	Active              bool
+	ConsecutiveFailures int32
+	LastError           sql.NullString
 }
 
 func (q *Queries) GetAllFeeds(ctx context.Context) ([]GetAllFeedsRow, error) {
@@ -84,7 +258,19 @@ func (q *Queries) GetAllFeeds(ctx context.Context) ([]GetAllFeedsRow, error) {
 	var items []GetAllFeedsRow
 	for rows.Next() {
 		var i GetAllFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.Name_2); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.Name_2,
+			&i.SiteLink,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.Generator,
+			&i.Active,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -116,10 +302,44 @@ func (q *Queries) GetFeed(ctx context.Context, arg GetFeedParams) (string, error
 	return name, err
 }
 
+const getFeedCacheHeaders = `-- name: GetFeedCacheHeaders :one
+
+
+SELECT etag, last_modified FROM feeds WHERE id = $1
+`
+
+type GetFeedCacheHeadersRow struct {
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) GetFeedCacheHeaders(ctx context.Context, id uuid.UUID) (GetFeedCacheHeadersRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedCacheHeaders, id)
+	var i GetFeedCacheHeadersRow
+	err := row.Scan(&i.Etag, &i.LastModified)
+	return i, err
+}
+
+const getFeedFailureCount = `-- name: GetFeedFailureCount :one
+
+
+SELECT consecutive_failures FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedFailureCount(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFailureCount, id)
+	var consecutive_failures int32
+	err := row.Scan(&consecutive_failures)
+	return consecutive_failures, err
+}
+
 const getFeedNamebyURL = `-- name: GetFeedNamebyURL :one
 
 
-SELECT name, id FROM feeds WHERE url = $1 LIMIT 1
+SELECT name, id FROM feeds
+WHERE url = $1
+OR id IN (SELECT feed_id FROM feed_urls WHERE feed_urls.url = $1)
+LIMIT 1
 `
 
 type GetFeedNamebyURLRow struct {
@@ -134,21 +354,166 @@ func (q *Queries) GetFeedNamebyURL(ctx context.Context, url string) (GetFeedName
 	return i, err
 }
 
+const getFeedRefreshHints = `-- name: GetFeedRefreshHints :one
+
+
+SELECT ttl_minutes, skip_hours, skip_days, update_period, update_frequency
+FROM feeds
+WHERE id = $1
+`
+
+type GetFeedRefreshHintsRow struct {
+	TtlMinutes      sql.NullInt32
+	SkipHours       []int32
+	SkipDays        []string
+	UpdatePeriod    sql.NullString
+	UpdateFrequency sql.NullInt32
+}
+
+func (q *Queries) GetFeedRefreshHints(ctx context.Context, id uuid.UUID) (GetFeedRefreshHintsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedRefreshHints, id)
+	var i GetFeedRefreshHintsRow
+	err := row.Scan(
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+	)
+	return i, err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 
 
 
-UPDATE feeds set last_fetched_at = $1, updated_at = $2
-WHERE id = $3
+UPDATE feeds
+set last_fetched_at = $1, updated_at = $2, etag = $3, last_modified = $4,
+    consecutive_failures = 0, last_error = NULL,
+    next_attempt_at = LOCALTIMESTAMP + $5::INTEGER * INTERVAL '1 second'
+WHERE id = $6
 `
 
 type MarkFeedFetchedParams struct {
-	LastFetchedAt sql.NullTime
-	UpdatedAt     time.Time
-	ID            uuid.UUID
+	LastFetchedAt      sql.NullTime
+	UpdatedAt          time.Time
+	Etag               sql.NullString
+	LastModified       sql.NullString
+	NextAttemptSeconds int32
+	ID                 uuid.UUID
 }
 
 func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.UpdatedAt, arg.ID)
+	_, err := q.db.ExecContext(ctx, markFeedFetched,
+		arg.LastFetchedAt,
+		arg.UpdatedAt,
+		arg.Etag,
+		arg.LastModified,
+		arg.NextAttemptSeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :exec
+
+
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1, last_error = $1,
+    next_attempt_at = LOCALTIMESTAMP + $2::INTEGER * INTERVAL '1 second', updated_at = $3
+WHERE id = $4
+`
+
+type RecordFeedFailureParams struct {
+	LastError    sql.NullString
+	RetrySeconds int32
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.RetrySeconds,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+
+
+UPDATE feeds SET lease_owner = NULL, lease_expires_at = NULL
+WHERE id = $1 AND lease_owner = $2
+`
+
+type ReleaseFeedLeaseParams struct {
+	ID         uuid.UUID
+	LeaseOwner sql.NullString
+}
+
+func (q *Queries) ReleaseFeedLease(ctx context.Context, arg ReleaseFeedLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.LeaseOwner)
+	return err
+}
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :exec
+
+
+UPDATE feeds
+SET site_link = $1, description = $2, language = $3, image_url = $4, generator = $5, updated_at = $6,
+    ttl_minutes = $7, skip_hours = $8, skip_days = $9, update_period = $10, update_frequency = $11
+WHERE id = $12
+`
+
+type UpdateFeedMetadataParams struct {
+	SiteLink        sql.NullString
+	Description     sql.NullString
+	Language        sql.NullString
+	ImageUrl        sql.NullString
+	Generator       sql.NullString
+	UpdatedAt       time.Time
+	TtlMinutes      sql.NullInt32
+	SkipHours       []int32
+	SkipDays        []string
+	UpdatePeriod    sql.NullString
+	UpdateFrequency sql.NullInt32
+	ID              uuid.UUID
+}
+
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedMetadata,
+		arg.SiteLink,
+		arg.Description,
+		arg.Language,
+		arg.ImageUrl,
+		arg.Generator,
+		arg.UpdatedAt,
+		arg.TtlMinutes,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+		arg.UpdatePeriod,
+		arg.UpdateFrequency,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+
+
+UPDATE feeds SET url = $1, updated_at = $2
+WHERE id = $3
+`
+
+type UpdateFeedURLParams struct {
+	Url       string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.Url, arg.UpdatedAt, arg.ID)
 	return err
 }
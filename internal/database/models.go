@@ -11,14 +11,53 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt64
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	LastFetchedAt       sql.NullTime
+	UserID              uuid.UUID
+	SiteLink            sql.NullString
+	Description         sql.NullString
+	Language            sql.NullString
+	ImageUrl            sql.NullString
+	Generator           sql.NullString
+	Etag                sql.NullString
+	LastModified        sql.NullString
+	Active              bool
+	ConsecutiveFailures int32
+	LastError           sql.NullString
+	NextAttemptAt       sql.NullTime
+	LeaseOwner          sql.NullString
+	LeaseExpiresAt      sql.NullTime
+	TtlMinutes          sql.NullInt32
+	SkipHours           []int32
+	SkipDays            []string
+	UpdatePeriod        sql.NullString
+	UpdateFrequency     sql.NullInt32
+}
+
+type FeedFetch struct {
+	ID           uuid.UUID
+	FeedID       uuid.UUID
+	StartedAt    time.Time
+	DurationMs   int32
+	StatusCode   sql.NullInt32
+	NewPosts     int32
+	UpdatedPosts int32
+	Error        sql.NullString
 }
 
 type FeedFollow struct {
@@ -29,6 +68,13 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FeedUrl struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Url       string
+}
+
 type Post struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -36,8 +82,37 @@ type Post struct {
 	Title       string
 	Url         string
 	Description string
-	PublishedAt time.Time
+	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        sql.NullString
+	Content     sql.NullString
+	ContentHash string
+	FirstSeenAt time.Time
+}
+
+type PostAuthor struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Name      string
+}
+
+type PostCategory struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Name      string
+}
+
+type PostRevision struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	PostID      uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	Content     sql.NullString
+	ContentHash string
 }
 
 type User struct {
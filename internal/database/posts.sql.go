@@ -7,72 +7,154 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (
-    id,
-    created_at,
-    updated_at,
-    title,
-    url,
-    description,
-    published_at,
-    feed_id
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+
+
+UPDATE posts
+SET guid = $1::TEXT
+WHERE feed_id = $2
+AND url = $3
+AND guid IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM posts p
+    WHERE p.feed_id = $2
+    AND COALESCE(p.guid, p.url) = $1::TEXT
+)
+`
+
+type AdoptLegacyPostParams struct {
+	Guid   string
+	FeedID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const createPostAuthor = `-- name: CreatePostAuthor :exec
+
+
+INSERT INTO post_authors (id, created_at, post_id, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (post_id, name) DO NOTHING
+`
+
+type CreatePostAuthorParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Name      string
+}
+
+func (q *Queries) CreatePostAuthor(ctx context.Context, arg CreatePostAuthorParams) error {
+	_, err := q.db.ExecContext(ctx, createPostAuthor,
+		arg.ID,
+		arg.CreatedAt,
+		arg.PostID,
+		arg.Name,
+	)
+	return err
+}
+
+const createPostCategory = `-- name: CreatePostCategory :exec
+
+
+INSERT INTO post_categories (id, created_at, post_id, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (post_id, name) DO NOTHING
 `
 
-type CreatePostParams struct {
+type CreatePostCategoryParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Name      string
+}
+
+func (q *Queries) CreatePostCategory(ctx context.Context, arg CreatePostCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, createPostCategory,
+		arg.ID,
+		arg.CreatedAt,
+		arg.PostID,
+		arg.Name,
+	)
+	return err
+}
+
+const createPostRevision = `-- name: CreatePostRevision :exec
+
+
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+SELECT $1::UUID, $2::TIMESTAMP, p.id, p.title, p.url, p.description, p.content, p.content_hash
+FROM posts p
+WHERE p.feed_id = $3::UUID
+AND COALESCE(p.guid, p.url) = $4::TEXT
+AND p.content_hash <> $5::TEXT
+`
+
+type CreatePostRevisionParams struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt time.Time
 	FeedID      uuid.UUID
+	PostKey     string
+	ContentHash string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRevision,
 		arg.ID,
 		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
 		arg.FeedID,
+		arg.PostKey,
+		arg.ContentHash,
 	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
-	)
-	return i, err
+	return err
+}
+
+const deletePostAuthors = `-- name: DeletePostAuthors :exec
+
+
+DELETE FROM post_authors WHERE post_id = $1
+`
+
+func (q *Queries) DeletePostAuthors(ctx context.Context, postID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePostAuthors, postID)
+	return err
+}
+
+const deletePostCategories = `-- name: DeletePostCategories :exec
+
+
+DELETE FROM post_categories WHERE post_id = $1
+`
+
+func (q *Queries) DeletePostCategories(ctx context.Context, postID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePostCategories, postID)
+	return err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
 
 
-SELECT f.name, p.title, p.url , p.description, p.published_at
+SELECT f.name, p.title, p.url , p.description, p.published_at, p.first_seen_at, p.content,
+    COALESCE((SELECT string_agg(pa.name, ', ' ORDER BY pa.name) FROM post_authors pa WHERE pa.post_id = p.id), '')::TEXT AS authors,
+    COALESCE((SELECT string_agg(pc.name, ', ' ORDER BY pc.name) FROM post_categories pc WHERE pc.post_id = p.id), '')::TEXT AS categories,
+    p.id
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
 WHERE ff.user_id = (
     SELECT u.id FROM users u WHERE u.name = $1
 )
-ORDER BY p.published_at DESC
+ORDER BY COALESCE(p.published_at, p.first_seen_at) DESC
 LIMIT $2
 `
 
@@ -86,7 +168,12 @@ type GetPostsForUserRow struct {
 	Title       string
 	Url         string
 	Description string
-	PublishedAt time.Time
+	PublishedAt sql.NullTime
+	FirstSeenAt time.Time
+	Content     sql.NullString
+	Authors     string
+	Categories  string
+	ID          uuid.UUID
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
@@ -104,6 +191,11 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Url,
 			&i.Description,
 			&i.PublishedAt,
+			&i.FirstSeenAt,
+			&i.Content,
+			&i.Authors,
+			&i.Categories,
+			&i.ID,
 		); err != nil {
 			return nil, err
 		}
@@ -117,3 +209,116 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getRecentPostTimes = `-- name: GetRecentPostTimes :many
+
+
+SELECT COALESCE(published_at, first_seen_at)::TIMESTAMP AS posted_at
+FROM posts
+WHERE feed_id = $1
+ORDER BY posted_at DESC
+LIMIT $2
+`
+
+type GetRecentPostTimesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPostTimes(ctx context.Context, arg GetRecentPostTimesParams) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPostTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var posted_at time.Time
+		if err := rows.Scan(&posted_at); err != nil {
+			return nil, err
+		}
+		items = append(items, posted_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (
+    id,
+    created_at,
+    updated_at,
+    title,
+    url,
+    description,
+    published_at,
+    feed_id,
+    guid,
+    content,
+    content_hash,
+    first_seen_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (feed_id, (COALESCE(guid, url))) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = COALESCE(EXCLUDED.published_at, posts.published_at),
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash
+WHERE posts.content_hash <> EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content, content_hash, first_seen_at
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Guid        sql.NullString
+	Content     sql.NullString
+	ContentHash string
+	FirstSeenAt time.Time
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.Content,
+		arg.ContentHash,
+		arg.FirstSeenAt,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.Content,
+		&i.ContentHash,
+		&i.FirstSeenAt,
+	)
+	return i, err
+}
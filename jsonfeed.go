@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Icon        string         `json:"icon"`
+	Favicon     string         `json:"favicon"`
+	Language    string         `json:"language"`
+	Authors     []JSONFeedUser `json:"authors"`
+	Author      *JSONFeedUser  `json:"author"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            json.RawMessage      `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	Tags          []string             `json:"tags"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []JSONFeedUser       `json:"authors"`
+	Author        *JSONFeedUser        `json:"author"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedUser struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	Title             string  `json:"title"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+// isJSONFeed reports whether a response looks like a JSON Feed, going by the
+// Content-Type header first and the first non-blank byte of the body second.
+func isJSONFeed(contentType string, body []byte) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "application/feed+json") || strings.Contains(contentType, "application/json") {
+		return true
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// parseJSONFeed decodes a JSON Feed (version 1.0 or 1.1) document and maps it
+// onto the RSSFeed model.
+func parseJSONFeed(body []byte) (*RSSFeed, error) {
+	var jsonFeed JSONFeed
+	err := json.Unmarshal(bytes.TrimPrefix(body, []byte("\ufeff")), &jsonFeed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json feed: %w", err)
+	}
+	if !strings.HasPrefix(jsonFeed.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("unsupported json feed version: %q", jsonFeed.Version)
+	}
+
+	var feed RSSFeed
+	feed.Channel.Title = jsonFeed.Title
+	feed.Channel.Link = jsonFeed.HomePageURL
+	feed.Channel.Description = jsonFeed.Description
+	feed.Channel.Language = jsonFeed.Language
+	feed.Channel.Image.URL = jsonFeed.Icon
+	if feed.Channel.Image.URL == "" {
+		feed.Channel.Image.URL = jsonFeed.Favicon
+	}
+
+	// Items without their own authors inherit the feed level authors.
+	feedAuthors := jsonFeedAuthors(jsonFeed.Authors, jsonFeed.Author)
+
+	for _, entry := range jsonFeed.Items {
+		item := RSSItem{
+			Title:      entry.Title,
+			Link:       entry.URL,
+			GUID:       RSSGUID{Value: jsonFeedID(entry.ID), IsPermaLink: "false"},
+			Authors:    jsonFeedAuthors(entry.Authors, entry.Author),
+			Categories: trimAll(entry.Tags),
+		}
+		if item.Link == "" {
+			item.Link = entry.ExternalURL
+		}
+		if len(item.Authors) == 0 {
+			item.Authors = feedAuthors
+		}
+
+		// content_text and summary are plain text, which is stored as HTML
+		// like the content of the other formats
+		item.Content = entry.ContentHTML
+		if item.Content == "" {
+			item.Content = textToHTML(entry.ContentText)
+		}
+
+		item.Description = textToHTML(entry.Summary)
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+
+		item.PubDate = entry.DatePublished
+		if item.PubDate == "" {
+			item.PubDate = entry.DateModified
+		}
+
+		for _, attachment := range entry.Attachments {
+			if attachment.URL == "" {
+				continue
+			}
+			item.Enclosures = append(item.Enclosures, RSSEnclosure{
+				URL:      attachment.URL,
+				Type:     attachment.MimeType,
+				Length:   strconv.FormatInt(attachment.SizeInBytes, 10),
+				Duration: int64(attachment.DurationInSeconds),
+			})
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
+
+// jsonFeedID returns an item id as a string. The spec asks for strings but
+// some 1.0 feeds publish numbers.
+func jsonFeedID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return strings.TrimSpace(id)
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number.String()
+	}
+	return ""
+}
+
+// jsonFeedAuthors returns the author names of a JSON Feed object. Version 1.1
+// uses an "authors" array while 1.0 used a single "author" object.
+func jsonFeedAuthors(authors []JSONFeedUser, author *JSONFeedUser) []string {
+	if len(authors) == 0 && author != nil {
+		authors = []JSONFeedUser{*author}
+	}
+
+	var names []string
+	for _, user := range authors {
+		name := strings.TrimSpace(user.Name)
+		if name == "" {
+			name = strings.TrimSpace(user.URL)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
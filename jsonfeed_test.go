@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestDecodeJSONFeed(t *testing.T) {
+	feed := decodeTestFeed(t, "feed.json", "application/feed+json")
+
+	if feed.Channel.Title != "Example JSON" {
+		t.Errorf("channel title = %q, want %q", feed.Channel.Title, "Example JSON")
+	}
+	if feed.Channel.Link != "https://example.com/" {
+		t.Errorf("channel link = %q, want %q", feed.Channel.Link, "https://example.com/")
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+
+	// content_text is plain text and must not be read as markup
+	checkFeedItem(t, feed.Channel.Item[0], RSSItem{
+		Title:       "A JSON post",
+		Link:        "https://example.com/posts/json",
+		GUID:        RSSGUID{Value: "1"},
+		Description: "<p>if a &lt; b &amp;&amp; c<br>then</p><p>next</p>",
+		Content:     "<p>if a &lt; b &amp;&amp; c<br>then</p><p>next</p>",
+		PubDate:     "2024-09-02T10:30:00Z",
+		Authors:     []string{"Jane Doe"},
+		Categories:  []string{"json"},
+		Enclosures:  []RSSEnclosure{{URL: "https://example.com/json.mp3", Type: "audio/mpeg", Length: "42", Duration: 90}},
+	})
+}
+
+func TestParseJSONFeedRejectsOtherJSON(t *testing.T) {
+	if _, err := parseJSONFeed([]byte(`{"id": 1, "title": {"rendered": "A WordPress post"}}`)); err == nil {
+		t.Error("parseJSONFeed accepted a document without a JSON Feed version")
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -15,6 +19,9 @@ import (
 	"html"
 	"io"
 	"net/http"
+	"net/url"
+	"os/signal"
+	"syscall"
 
 	"github.com/1729prashant/blog-aggregator/internal/config"
 	"github.com/1729prashant/blog-aggregator/internal/database"
@@ -23,8 +30,10 @@ import (
 )
 
 type state struct {
-	db     *database.Queries
-	config *config.Config
+	db      *database.Queries
+	sqlDB   *sql.DB
+	config  *config.Config
+	fetcher *fetcher
 }
 
 type command struct {
@@ -155,66 +164,324 @@ func GetUsers(s *state, cmd command) error {
 }
 
 type RSSFeed struct {
+	Base    string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Base  string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+		Title string `xml:"title"`
+		// atom:link elements are listed before link so they don't overwrite
+		// the channel's own link when both are present.
+		AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Language    string     `xml:"language"`
+		Generator   string     `xml:"generator"`
+		ITunesImage struct {
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		Image struct {
+			URL string `xml:"url"`
+		} `xml:"image"`
+		// Hints on how often the feed is worth fetching
+		TTL             string    `xml:"ttl"`
+		SkipHours       []string  `xml:"skipHours>hour"`
+		SkipDays        []string  `xml:"skipDays>day"`
+		UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		Item            []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Base        string         `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	GUID        RSSGUID        `xml:"guid"`
+	Description string         `xml:"description"`
+	Content     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string         `xml:"pubDate"`
+	DCDate      string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	AtomUpdated string         `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors     []string       `xml:"author"`
+	Creators    []string       `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string       `xml:"category"`
+	Enclosures  []RSSEnclosure `xml:"enclosure"`
+	// Podcast and Media RSS extensions, folded into Enclosures by parseRSSFeed
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	MediaContents  []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups    []struct {
+		Contents []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	// Create a new HTTP request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+type RSSGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+type RSSEnclosure struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Length   string `xml:"length,attr"`
+	Duration int64  `xml:"-"` // seconds, 0 when unknown
+}
+
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// decodeFeed parses a fetched document into an RSSFeed and cleans up its text fields.
+func decodeFeed(res *fetchResponse) (*RSSFeed, error) {
+	// Feeds in legacy encodings are converted before parsing
+	body, err := toUTF8(res.Body, res.ContentType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Parse the body into an RSSFeed struct, whatever the feed format
+	response, err := parseFeedBody(body, res.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Make relative links absolute before they get stored
+	resolveFeedURLs(response, res.URL)
+
+	// Unescape HTML entities in the plain text fields. Item descriptions and
+	// content are HTML that the decoder already unescaped once; unescaping
+	// them again would turn escaped code samples into markup
+	response.Channel.Title = html.UnescapeString(response.Channel.Title)
+	response.Channel.Description = html.UnescapeString(response.Channel.Description)
+	for i := range response.Channel.Item {
+		response.Channel.Item[i].Title = html.UnescapeString(response.Channel.Item[i].Title)
 	}
 
-	// Set the User-Agent header
-	req.Header.Add("User-Agent", "gator")
+	return response, nil
+}
 
-	// Execute the request using an HTTP client
-	client := &http.Client{}
-	res, err := client.Do(req)
+// resolveFeedURLs makes the links, enclosures and images of a feed absolute.
+// Relative references are resolved against xml:base when the feed sets it,
+// then against the channel link and finally against the feed URL.
+func resolveFeedURLs(feed *RSSFeed, feedURL string) {
+	feedBase, err := url.Parse(feedURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch RSS: %w", err)
+		return
 	}
-	defer res.Body.Close()
+	feedBase = resolveBase(feedBase, feed.Base)
+	feedBase = resolveBase(feedBase, feed.Channel.Base)
 
-	// Check for non-success HTTP status codes
-	if res.StatusCode >= 300 {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	feed.Channel.Link = resolveLink(feedBase, feed.Channel.Link)
+	feed.Channel.Image.URL = resolveLink(feedBase, feed.Channel.Image.URL)
+
+	// Without xml:base, relative item links are taken to be relative to the site
+	itemBase := feedBase
+	if feed.Base == "" && feed.Channel.Base == "" {
+		if siteURL, err := url.Parse(feed.Channel.Link); err == nil && siteURL.IsAbs() {
+			itemBase = siteURL
+		}
 	}
 
-	// Read the response body
-	body, err := io.ReadAll(res.Body)
+	for i := range feed.Channel.Item {
+		item := &feed.Channel.Item[i]
+		base := itemBase
+		if item.Base != "" {
+			base = resolveBase(feedBase, item.Base)
+		}
+
+		item.Link = resolveLink(base, item.Link)
+		for j := range item.Enclosures {
+			item.Enclosures[j].URL = resolveLink(base, item.Enclosures[j].URL)
+		}
+	}
+}
+
+// resolveBase applies an xml:base attribute on top of the current base URL.
+func resolveBase(base *url.URL, xmlBase string) *url.URL {
+	xmlBase = strings.TrimSpace(xmlBase)
+	if xmlBase == "" {
+		return base
+	}
+	ref, err := url.Parse(xmlBase)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return base
 	}
+	return base.ResolveReference(ref)
+}
 
-	// Parse the XML into an RSSFeed struct
-	var response RSSFeed
-	err = xml.Unmarshal(body, &response)
+// resolveLink resolves a possibly relative link against base. Links that
+// don't parse are returned as they are.
+func resolveLink(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	ref, err := url.Parse(link)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return link
 	}
+	return base.ResolveReference(ref).String()
+}
 
-	// Unescape HTML entities in Titles and Descriptions
-	response.Channel.Title = html.UnescapeString(response.Channel.Title)
-	response.Channel.Description = html.UnescapeString(response.Channel.Description)
-	for i := range response.Channel.Item {
-		response.Channel.Item[i].Title = html.UnescapeString(response.Channel.Item[i].Title)
-		response.Channel.Item[i].Description = html.UnescapeString(response.Channel.Item[i].Description)
+// parseFeedBody detects the feed format from the Content-Type header and the
+// document itself and decodes it with the matching parser.
+func parseFeedBody(body []byte, contentType string) (*RSSFeed, error) {
+	if isJSONFeed(contentType, body) {
+		return parseJSONFeed(body)
 	}
 
-	return &response, nil
+	root, err := feedRootElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		return parseRSSFeed(body)
+	case "feed":
+		return parseAtomFeed(body)
+	case "RDF":
+		return parseRDFFeed(body)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+}
+
+// parseRSSFeed decodes an RSS 2.0 document and folds the fields that have
+// several possible sources into the ones the rest of the program reads.
+func parseRSSFeed(body []byte) (*RSSFeed, error) {
+	var feed RSSFeed
+	err := xml.Unmarshal(body, &feed)
+	if err != nil {
+		return nil, err
+	}
+
+	if feed.Channel.Image.URL == "" {
+		feed.Channel.Image.URL = feed.Channel.ITunesImage.Href
+	}
+
+	for i := range feed.Channel.Item {
+		item := &feed.Channel.Item[i]
+		item.GUID.Value = strings.TrimSpace(item.GUID.Value)
+		item.Authors = trimAll(append(item.Authors, item.Creators...))
+		item.Categories = trimAll(item.Categories)
+
+		// Some RSS 2.0 feeds date their items with Dublin Core or Atom elements
+		if strings.TrimSpace(item.PubDate) == "" {
+			item.PubDate = item.DCDate
+		}
+		if strings.TrimSpace(item.PubDate) == "" {
+			item.PubDate = item.AtomUpdated
+		}
+
+		// Media RSS content is treated like extra enclosures
+		mediaContents := item.MediaContents
+		for _, group := range item.MediaGroups {
+			mediaContents = append(mediaContents, group.Contents...)
+		}
+		for _, media := range mediaContents {
+			if strings.TrimSpace(media.URL) == "" || hasEnclosure(item.Enclosures, media.URL) {
+				continue
+			}
+			item.Enclosures = append(item.Enclosures, RSSEnclosure{
+				URL:      strings.TrimSpace(media.URL),
+				Type:     media.Type,
+				Length:   media.FileSize,
+				Duration: parseMediaDuration(media.Duration),
+			})
+		}
+		if duration := parseMediaDuration(item.ITunesDuration); duration > 0 {
+			for j := range item.Enclosures {
+				if item.Enclosures[j].Duration == 0 {
+					item.Enclosures[j].Duration = duration
+				}
+			}
+		}
+
+		// A guid is a permalink unless it says otherwise, which makes it a
+		// usable link for items that have none.
+		if strings.TrimSpace(item.Link) == "" && item.GUID.IsPermaLink != "false" && isHTTPURL(item.GUID.Value) {
+			item.Link = item.GUID.Value
+		}
+	}
+
+	return &feed, nil
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL.
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// feedRootElement returns the local name of the first element in an XML document.
+func feedRootElement(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to find root element: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// timezoneOffsets maps the zone names found in feed dates to their UTC
+// offsets. time.Parse only knows the offset of the local zone's name, so
+// these are substituted before parsing.
+var timezoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"SGT":  "+0800",
+	"HKT":  "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// normalizeFeedDate collapses whitespace, drops trailing comments such as
+// "(EST)" and replaces a trailing zone name with its numeric offset.
+func normalizeFeedDate(dateStr string) string {
+	fields := strings.Fields(dateStr)
+	if len(fields) > 1 && strings.HasPrefix(fields[len(fields)-1], "(") {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) > 1 {
+		if offset, ok := timezoneOffsets[strings.ToUpper(fields[len(fields)-1])]; ok {
+			fields[len(fields)-1] = offset
+		}
+	}
+	return strings.Join(fields, " ")
 }
 
 // parseFeedDate attempts to parse RSS feed dates in various formats
@@ -228,86 +495,529 @@ func parseFeedDate(dateStr string) (time.Time, error) {
 		"Mon, 02 Jan 2006 15:04:05 -0700",
 		"02 Jan 2006 15:04:05 -0700",
 		"2006-01-02 15:04:05",
+		// RFC 822 variants: single digit days, missing seconds or weekday, two digit years
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04 -0700",
+		"2 Jan 2006 15:04:05 -0700",
+		"2 Jan 2006 15:04 -0700",
+		"Mon, 2 Jan 06 15:04:05 -0700",
+		"Mon, 2 Jan 06 15:04 -0700",
+		"2 Jan 06 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05",
+		"Monday, 2 January 2006 15:04:05 -0700",
+		"Mon, 2 January 2006 15:04:05 -0700",
+		"Mon Jan 2 15:04:05 -0700 2006",
+		"Mon Jan 2 15:04:05 2006",
+		// ISO 8601 / W3CDTF variants, as used by Atom and dc:date
+		"2006-01-02T15:04:05-0700",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05-07",
+		"2006-01-02 15:04",
+		"20060102T150405Z0700",
+		"20060102T150405",
+		"2006-01-02",
+		"2006-01",
+		"January 2, 2006",
 	}
 
+	normalized := normalizeFeedDate(dateStr)
 	for _, layout := range layouts {
-		if t, err := time.Parse(layout, dateStr); err == nil {
+		if t, err := time.Parse(layout, normalized); err == nil {
 			return t, nil
 		}
 	}
 	return time.Time{}, fmt.Errorf("unable to parse date: %s", dateStr)
 }
 
-func scrapeFeeds(s *state) error {
-	ctx := context.Background()
+// scrapeFeeds claims the feeds that are due for fetching and queues them with
+// the workers of the aggregator, oldest first, up to its batch size. Only the
+// feeds followed by the users the aggregator serves are considered, and feeds
+// leased by another agg process are skipped.
+func scrapeFeeds(ctx context.Context, s *state, agg *aggregator) error {
+	// Claim no more than the workers and their queue can take right away, so
+	// that claimed feeds don't sit in the queue while their lease runs out
+	maxFeeds := min(agg.batchSize, agg.concurrency+agg.batchSize-agg.busy())
+	if maxFeeds <= 0 {
+		return nil
+	}
 
-	// Get the next feed to fetch (the one with oldest or null last_fetched_at)
-	feedURL, err := s.db.GetNextFeedToFetch(context.Background(), s.config.Name)
+	feeds, err := s.db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		Owner:        sql.NullString{String: agg.owner, Valid: true},
+		LeaseSeconds: int32(agg.lease.Seconds()),
+		AllUsers:     agg.allUsers,
+		UserNames:    agg.userNames,
+		MaxFeeds:     int32(maxFeeds),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get next feed: %w", err)
+		return fmt.Errorf("failed to claim next feeds: %w", err)
+	}
+
+	for _, feed := range feeds {
+		// A lease that ran out while the feed was still being fetched
+		if !agg.claim(feed.ID) {
+			continue
+		}
+		agg.queue(feed)
+	}
+	if len(feeds) == 0 && agg.busy() == 0 {
+		fmt.Println("\nNo feeds due for fetching")
 	}
 
-	// Fetch the feed content
-	rssFeed, err := fetchFeed(ctx, feedURL)
+	return nil
+}
+
+// scrapeFeed fetches one feed and adds the attempt to the fetch history,
+// whether it succeeded or not. The history is written even when ctx was
+// canceled during the fetch.
+func scrapeFeed(ctx context.Context, s *state, out io.Writer, feed database.ClaimFeedsToFetchRow, policy refreshPolicy) (feedFetchResult, error) {
+	startedAt := time.Now()
+	result, err := fetchFeedPosts(ctx, s, out, feed, policy)
+	logErr := recordFeedFetch(context.WithoutCancel(ctx), s, feed.ID, startedAt, result, err)
 	if err != nil {
-		return fmt.Errorf("failed to fetch feed %s: %w", feedURL, err)
+		return result, err
 	}
+	return result, logErr
+}
 
-	feedNameAndID, err := s.db.GetFeedNamebyURL(context.Background(), feedURL)
+// feedFetchResult is what a fetch of a feed produced, for the fetch history.
+type feedFetchResult struct {
+	StatusCode   int
+	NewPosts     int
+	UpdatedPosts int
+}
+
+// fetchFeedPosts fetches one feed and saves its new and changed posts.
+func fetchFeedPosts(ctx context.Context, s *state, out io.Writer, feed database.ClaimFeedsToFetchRow, policy refreshPolicy) (feedFetchResult, error) {
+	var result feedFetchResult
+	feedID, feedName, feedURL := feed.ID, feed.Name, feed.Url
+
+	cacheHeaders, err := s.db.GetFeedCacheHeaders(ctx, feedID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch feed name for url, consider adding the feed first ...: %v", err)
+		return result, fmt.Errorf("failed to fetch cache headers for feed %s: %w", feedURL, err)
 	}
 
-	// Process and save each post
-	for _, item := range rssFeed.Channel.Item {
-		// Parse the publication date
-		pubDate, err := parseFeedDate(item.PubDate)
-		if err != nil {
-			fmt.Printf("Warning: couldn't parse date for post '%s': %v\n", item.Title, err)
-			// Use current time as fallback
-			pubDate = time.Now()
-		}
-
-		now := time.Now()
-		_, err = s.db.CreatePost(ctx, database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   now,
-			UpdatedAt:   now,
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: item.Description,
-			PublishedAt: pubDate,
-			FeedID:      feedNameAndID.ID,
+	// Fetch the feed content, unless it hasn't changed since the last fetch
+	res, err := s.fetcher.fetchURL(ctx, feedURL, cacheValidators{
+		ETag:         cacheHeaders.Etag.String,
+		LastModified: cacheHeaders.LastModified.String,
+	})
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		result.StatusCode = statusErr.StatusCode
+	}
+	if result.StatusCode == http.StatusGone {
+		// The feed has been removed for good, stop fetching it
+		err = s.db.DeactivateFeed(ctx, database.DeactivateFeedParams{
+			UpdatedAt: time.Now(),
+			ID:        feedID,
 		})
 		if err != nil {
-			// Check if it's a uniqueness violation
-			if strings.Contains(err.Error(), "unique constraint") {
-				continue // Skip duplicates silently
-			}
-			fmt.Printf("Error saving post '%s': %v\n", item.Title, err)
-			continue
+			return result, fmt.Errorf("failed to deactivate feed %s: %w", feedURL, err)
 		}
+		fmt.Fprintf(out, "\nFeed: %s is gone (410), it will no longer be fetched\n", feedName)
+		return result, nil
+	}
+	if err != nil && ctx.Err() != nil {
+		// Canceled on shutdown or because another process took over the
+		// lease, which is no fault of the feed
+		fmt.Fprintf(out, "\nFeed: %s\n", feedName)
+		return result, fmt.Errorf("fetch of feed %s canceled: %w", feedURL, ctx.Err())
+	}
+	if err != nil {
+		fmt.Fprintf(out, "\nFeed: %s\n", feedName)
+		return result, failFeed(ctx, s, out, feedID, fmt.Errorf("failed to fetch feed %s: %w", feedURL, err))
+	}
+	result.StatusCode = res.StatusCode
+
+	fmt.Fprintf(out, "\nFeed: %s\n", feedName)
+	if res.NotModified {
+		fmt.Fprintln(out, "Not modified since the last fetch")
+		followPermanentRedirect(ctx, s, out, feedID, feedURL, res)
+		return result, markFeedFetched(ctx, s, out, feedID, res, policy)
+	}
+
+	rssFeed, err := decodeFeed(res)
+	if err != nil {
+		return result, failFeed(ctx, s, out, feedID, fmt.Errorf("failed to parse feed %s: %w", feedURL, err))
 	}
+	// Only a redirect to something that parses as a feed is followed for good
+	followPermanentRedirect(ctx, s, out, feedID, feedURL, res)
 
-	// Print feed items
-	fmt.Printf("\nFeed: %s\n", feedNameAndID.Name)
+	// Refresh the channel metadata
+	err = saveFeedMetadata(ctx, s, feedID, rssFeed)
+	if err != nil {
+		return result, err
+	}
+
+	// Process and save each post, counting what was new, changed or already stored
+	skippedPosts, failedPosts := 0, 0
+	// Posts that failed to save, as opposed to items that can't be stored
+	// at all, are worth another try
+	unsavedPosts := 0
 	for _, item := range rssFeed.Channel.Item {
-		fmt.Printf("- %s\n", item.Title)
+		// Stop on shutdown, the posts saved so far are committed and the
+		// rest are picked up by the next fetch
+		if ctx.Err() != nil {
+			fmt.Fprintf(out, "%d new, %d updated, %d skipped, %d failed before the fetch was canceled\n", result.NewPosts, result.UpdatedPosts, skippedPosts, failedPosts)
+			return result, fmt.Errorf("fetch of feed %s canceled: %w", feedURL, ctx.Err())
+		}
+
+		// Items are identified by their guid, or their link when they have none
+		if strings.TrimSpace(item.GUID.Value) == "" && strings.TrimSpace(item.Link) == "" {
+			fmt.Fprintf(out, "Warning: skipping post '%s' without a link or guid\n", item.Title)
+			failedPosts++
+			continue
+		}
+
+		saved, err := savePost(ctx, s, out, feedID, item)
+		if err != nil {
+			fmt.Fprintf(out, "Error saving post '%s': %v\n", item.Title, err)
+			failedPosts++
+			unsavedPosts++
+			continue
+		}
+
+		switch saved {
+		case postCreated:
+			result.NewPosts++
+			fmt.Fprintf(out, "- %s\n", item.Title)
+		case postUpdated:
+			result.UpdatedPosts++
+			fmt.Fprintf(out, "~ %s (updated)\n", item.Title)
+		default:
+			skippedPosts++
+		}
+	}
+	fmt.Fprintf(out, "%d new, %d updated, %d skipped, %d failed\n", result.NewPosts, result.UpdatedPosts, skippedPosts, failedPosts)
+
+	if unsavedPosts > 0 {
+		// Drop the validators, or the next fetch would get a 304 and the
+		// posts that failed wouldn't be retried until the feed changes
+		fmt.Fprintln(out, "The whole feed will be downloaded again on the next fetch")
+		uncached := *res
+		uncached.ETag, uncached.LastModified = "", ""
+		res = &uncached
+	}
+
+	return result, markFeedFetched(ctx, s, out, feedID, res, policy)
+}
+
+// recordFeedFetch adds an attempt to fetch a feed to its fetch history.
+func recordFeedFetch(ctx context.Context, s *state, feedID uuid.UUID, startedAt time.Time, result feedFetchResult, fetchErr error) error {
+	var errorMessage sql.NullString
+	if fetchErr != nil {
+		errorMessage = sql.NullString{String: fetchErr.Error(), Valid: true}
 	}
 
-	// Mark the feed as fetched
-	err = s.db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
-		LastFetchedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		UpdatedAt:     time.Now(),
-		ID:            feedNameAndID.ID,
+	err := s.db.CreateFeedFetch(ctx, database.CreateFeedFetchParams{
+		ID:           uuid.New(),
+		FeedID:       feedID,
+		StartedAt:    startedAt,
+		DurationMs:   int32(time.Since(startedAt).Milliseconds()),
+		StatusCode:   sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0},
+		NewPosts:     int32(result.NewPosts),
+		UpdatedPosts: int32(result.UpdatedPosts),
+		Error:        errorMessage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record fetch history: %w", err)
+	}
+	return nil
+}
+
+// followPermanentRedirect moves a feed to the URL it was permanently
+// redirected to, if any. It is called once the response is known to be the
+// feed, so that a redirect to e.g. a parking page doesn't replace the URL.
+func followPermanentRedirect(ctx context.Context, s *state, out io.Writer, feedID uuid.UUID, feedURL string, res *fetchResponse) {
+	if res.PermanentURL == "" || res.PermanentURL == feedURL {
+		return
+	}
+	err := moveFeed(ctx, s, out, feedID, feedURL, res.PermanentURL)
+	if err != nil {
+		// Not fatal, the redirect is followed again on the next fetch
+		fmt.Fprintf(out, "Warning: %v\n", err)
+	}
+}
+
+// moveFeed points a feed at the URL it permanently moved to. The old URL is
+// kept in the feed's URL history so that it can still be used to follow or
+// unfollow the feed.
+func moveFeed(ctx context.Context, s *state, out io.Writer, feedID uuid.UUID, oldURL, newURL string) error {
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	now := time.Now()
+	err = qtx.CreateFeedURL(ctx, database.CreateFeedURLParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		FeedID:    feedID,
+		Url:       oldURL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record previous url %s: %w", oldURL, err)
+	}
+
+	err = qtx.UpdateFeedURL(ctx, database.UpdateFeedURLParams{
+		Url:       newURL,
+		UpdatedAt: now,
+		ID:        feedID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move feed to %s: %w", newURL, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit feed move: %w", err)
+	}
+
+	fmt.Fprintf(out, "Feed moved permanently from %s to %s\n", oldURL, newURL)
+	return nil
+}
+
+// markFeedFetched records the fetch time of a feed together with the cache
+// validators of the response, for the next conditional request, and
+// schedules the next fetch.
+func markFeedFetched(ctx context.Context, s *state, out io.Writer, feedID uuid.UUID, res *fetchResponse, policy refreshPolicy) error {
+	nextFetch, err := scheduleNextFetch(ctx, s, feedID, res, policy)
+	if err != nil {
+		return err
+	}
+
+	// The database adds the delay to its own clock, the one leases and due
+	// feeds are checked against, whatever the clock of this host
+	err = s.db.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		LastFetchedAt:      sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:          time.Now(),
+		Etag:               nullString(res.ETag),
+		LastModified:       nullString(res.LastModified),
+		NextAttemptSeconds: delaySeconds(time.Until(nextFetch)),
+		ID:                 feedID,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to mark feed as fetched: %w", err)
 	}
 
+	fmt.Fprintf(out, "Next fetch at %s\n", nextFetch.Format("2006-01-02 15:04:05"))
 	return nil
 }
 
+type postSaveResult int
+
+const (
+	postUnchanged postSaveResult = iota
+	postCreated
+	postUpdated
+)
+
+// savePost inserts a feed item as a post, or updates the stored post when
+// the item changed since it was last seen. The previous version of an
+// updated post is kept in post_revisions.
+func savePost(ctx context.Context, s *state, out io.Writer, feedID uuid.UUID, item RSSItem) (postSaveResult, error) {
+	// Parse the publication date, leaving it empty rather than guessing
+	pubDate := sql.NullTime{}
+	if strings.TrimSpace(item.PubDate) != "" {
+		parsed, err := parseFeedDate(item.PubDate)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: couldn't parse date for post '%s': %v\n", item.Title, err)
+		} else {
+			pubDate = sql.NullTime{Time: parsed, Valid: true}
+		}
+	}
+
+	now := time.Now()
+	params := database.UpsertPostParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Title:       item.Title,
+		Url:         item.Link,
+		Description: sanitizeHTML(item.Description),
+		PublishedAt: pubDate,
+		FeedID:      feedID,
+		Guid:        nullString(item.GUID.Value),
+		Content:     nullString(sanitizeHTML(item.Content)),
+		FirstSeenAt: now,
+	}
+	params.ContentHash = postContentHash(params)
+
+	// The same key as the posts_feed_id_guid_key index
+	postKey := params.Url
+	if params.Guid.Valid {
+		postKey = params.Guid.String
+	}
+
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return postUnchanged, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	// Posts stored before guids were kept are known by their url only. Give
+	// such a post the item's guid so that the item updates it rather than
+	// adding it a second time
+	if params.Guid.Valid && params.Guid.String != params.Url {
+		err = qtx.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{
+			Guid:   params.Guid.String,
+			FeedID: feedID,
+			Url:    params.Url,
+		})
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to match post saved without guid: %w", err)
+		}
+	}
+
+	// Keep the stored version if the item changed, this is a no-op for new
+	// and unchanged items
+	err = qtx.CreatePostRevision(ctx, database.CreatePostRevisionParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		FeedID:      feedID,
+		PostKey:     postKey,
+		ContentHash: params.ContentHash,
+	})
+	if err != nil {
+		return postUnchanged, fmt.Errorf("failed to save revision: %w", err)
+	}
+
+	post, err := qtx.UpsertPost(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The conflict update is skipped for unchanged posts, so no row comes back
+		return postUnchanged, nil
+	}
+	if err != nil {
+		return postUnchanged, err
+	}
+
+	result := postCreated
+	if post.ID != params.ID {
+		// The conflict update keeps the id of the stored post
+		result = postUpdated
+		err = qtx.DeletePostAuthors(ctx, post.ID)
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to clear authors: %w", err)
+		}
+		err = qtx.DeletePostCategories(ctx, post.ID)
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to clear categories: %w", err)
+		}
+		err = qtx.DeletePostEnclosures(ctx, post.ID)
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to clear enclosures: %w", err)
+		}
+	}
+
+	err = savePostDetails(ctx, qtx, post.ID, item)
+	if err != nil {
+		return postUnchanged, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return postUnchanged, fmt.Errorf("failed to commit post: %w", err)
+	}
+	return result, nil
+}
+
+// postContentHash fingerprints the parts of a post that authors edit after
+// publishing. It must match the backfill in sql/schema/008_post_revisions.sql.
+func postContentHash(post database.UpsertPostParams) string {
+	sum := sha256.Sum256([]byte(post.Title + "\n" + post.Url + "\n" + post.Description + "\n" + post.Content.String))
+	return hex.EncodeToString(sum[:])
+}
+
+// savePostDetails stores the authors, categories and enclosures of a post.
+func savePostDetails(ctx context.Context, db *database.Queries, postID uuid.UUID, item RSSItem) error {
+	now := time.Now()
+	for _, author := range item.Authors {
+		err := db.CreatePostAuthor(ctx, database.CreatePostAuthorParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			PostID:    postID,
+			Name:      author,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save author '%s': %w", author, err)
+		}
+	}
+
+	for _, category := range item.Categories {
+		err := db.CreatePostCategory(ctx, database.CreatePostCategoryParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			PostID:    postID,
+			Name:      category,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save category '%s': %w", category, err)
+		}
+	}
+
+	for _, enclosure := range item.Enclosures {
+		enclosureURL := strings.TrimSpace(enclosure.URL)
+		if enclosureURL == "" {
+			continue
+		}
+		// Feeds often put 0 or junk in the length attribute
+		var length sql.NullInt64
+		if n, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64); err == nil && n > 0 {
+			length = sql.NullInt64{Int64: n, Valid: true}
+		}
+		err := db.CreateEnclosure(ctx, database.CreateEnclosureParams{
+			ID:              uuid.New(),
+			CreatedAt:       now,
+			PostID:          postID,
+			Url:             enclosureURL,
+			MimeType:        nullString(enclosure.Type),
+			Length:          length,
+			DurationSeconds: sql.NullInt64{Int64: enclosure.Duration, Valid: enclosure.Duration > 0},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save enclosure '%s': %w", enclosureURL, err)
+		}
+	}
+
+	return nil
+}
+
+// saveFeedMetadata stores the channel level information of a fetched feed.
+func saveFeedMetadata(ctx context.Context, s *state, feedID uuid.UUID, rssFeed *RSSFeed) error {
+	channel := rssFeed.Channel
+	err := s.db.UpdateFeedMetadata(ctx, database.UpdateFeedMetadataParams{
+		SiteLink:        nullString(channel.Link),
+		Description:     nullString(channel.Description),
+		Language:        nullString(channel.Language),
+		ImageUrl:        nullString(channel.Image.URL),
+		Generator:       nullString(channel.Generator),
+		UpdatedAt:       time.Now(),
+		TtlMinutes:      positiveInt(channel.TTL),
+		SkipHours:       parseSkipHours(channel.SkipHours),
+		SkipDays:        parseSkipDays(channel.SkipDays),
+		UpdatePeriod:    parseUpdatePeriod(channel.UpdatePeriod),
+		UpdateFrequency: positiveInt(channel.UpdateFrequency),
+		ID:              feedID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save feed metadata: %w", err)
+	}
+	return nil
+}
+
+// nullString maps empty (or blank) strings to NULL.
+func nullString(value string) sql.NullString {
+	value = strings.TrimSpace(value)
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
 func handlerAgg(s *state, cmd command) error {
 	// ctx := context.Background()
 	// feedURL := "https://www.wagslane.dev/index.xml" //this needs to change, no hardcoding
@@ -332,32 +1042,95 @@ func handlerAgg(s *state, cmd command) error {
 		return fmt.Errorf("invalid duration format: %v", err)
 	}
 
-	fmt.Printf("Collecting feeds every %v\n", timeBetweenRequests)
+	// Ctrl-C or SIGTERM stops agg once the feeds being fetched are done and
+	// a second one cancels them. The signals stay caught until agg returns,
+	// so that neither of them kills the process halfway through saving
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	agg, err := newAggregator(s, s.config.Agg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Collecting feeds of %s every %v with %d workers\n", agg.scope(), timeBetweenRequests, agg.concurrency)
+	startedAt := time.Now()
 
 	// Create a ticker for periodic execution
 	ticker := time.NewTicker(timeBetweenRequests)
 	defer ticker.Stop()
 
-	// Run immediately and then on every tick
-	for ; ; <-ticker.C {
-		err := scrapeFeeds(s)
-		if err != nil {
-			fmt.Printf("Error scraping feeds: %v\n", err)
+	// Run immediately and then on every tick until agg is stopped
+	for ctx.Err() == nil {
+		err := scrapeFeeds(ctx, s, agg)
+		if err != nil && ctx.Err() == nil {
 			// Continue running even if there's an error
-			continue
+			fmt.Printf("Error scraping feeds: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
 		}
 	}
+
+	shutdownAggregator(agg, signals)
+
+	stats := agg.summary()
+	fmt.Printf("\nFetched %d feeds (%d failed) in %v: %d new posts, %d updated posts\n",
+		stats.Fetched, stats.Failed, time.Since(startedAt).Round(time.Second), stats.NewPosts, stats.UpdatedPosts)
+	return nil
+}
+
+// shutdownAggregator gives back the queued feeds and waits for the feeds
+// being fetched. A further signal cancels those fetches instead.
+func shutdownAggregator(agg *aggregator, signals <-chan os.Signal) {
+	fmt.Println("\nShutting down, finishing the feeds being fetched (press Ctrl-C again to cancel them)")
+	agg.stop()
+
+	done := make(chan struct{})
+	go func() {
+		agg.close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-signals:
+		fmt.Println("\nCanceling the fetches in progress")
+		agg.cancel()
+		<-done
+	}
 }
 
 // Add the browse command handler
 func handlerBrowse(s *state, cmd command) error {
-	limit := 2 // Default limit
-	if len(cmd.args) > 0 {
-		parsedLimit, err := strconv.Atoi(cmd.args[0])
-		if err != nil {
-			return fmt.Errorf("invalid limit parameter: %v", err)
+	limit := 2       // Default limit
+	mode := "render" // How post bodies are printed: render, raw or summary
+	for _, arg := range cmd.args {
+		switch arg {
+		case "--raw":
+			mode = "raw"
+		case "--summary":
+			mode = "summary"
+		default:
+			parsedLimit, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("invalid limit parameter: %v", err)
+			}
+			limit = parsedLimit
 		}
-		limit = parsedLimit
 	}
 
 	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
@@ -377,9 +1150,49 @@ func handlerBrowse(s *state, cmd command) error {
 
 	for _, post := range posts {
 		fmt.Println("-----------------------------")
-		fmt.Printf("%s - %s (%s)\n", post.Name, post.Title, post.PublishedAt.Format("2006-01-02 15:04:05"))
+		if post.PublishedAt.Valid {
+			fmt.Printf("%s - %s (%s)\n", post.Name, post.Title, post.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%s - %s (first seen %s)\n", post.Name, post.Title, post.FirstSeenAt.Format("2006-01-02 15:04:05"))
+		}
+		if post.Authors != "" {
+			fmt.Printf("By: %s\n", post.Authors)
+		}
+		if post.Categories != "" {
+			fmt.Printf("Categories: %s\n", post.Categories)
+		}
+		enclosures, err := s.db.GetEnclosuresForPost(context.Background(), post.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch enclosures: %v", err)
+		}
+		for _, enclosure := range enclosures {
+			summary := enclosureSummary(enclosure.MimeType.String, enclosure.Length.Int64, enclosure.DurationSeconds.Int64)
+			if summary != "" {
+				fmt.Printf("Enclosure: %s (%s)\n", enclosure.Url, summary)
+			} else {
+				fmt.Printf("Enclosure: %s\n", enclosure.Url)
+			}
+		}
 		fmt.Println("*****************************")
-		fmt.Printf("%s\n", post.Description)
+		// Show the full text when the feed publishes it
+		body := post.Description
+		if post.Content.Valid {
+			body = post.Content.String
+		}
+		switch mode {
+		case "raw":
+			fmt.Printf("%s\n", body)
+		case "summary":
+			// Summaries come from the short description when there is one
+			summary := post.Description
+			if strings.TrimSpace(summary) == "" {
+				summary = body
+			}
+			fmt.Printf("%s\n", renderSummary(summary, 280))
+			fmt.Printf("%s\n", post.Url)
+		default:
+			fmt.Printf("%s\n", renderHTML(body, terminalWidth()))
+		}
 		fmt.Println("-----------------------------")
 	}
 	/*
@@ -400,27 +1213,55 @@ func handlerBrowse(s *state, cmd command) error {
 }
 
 func handlerAddFeed(s *state, cmd command, userUUID uuid.UUID) error {
-	if len(cmd.args) < 2 {
-		return fmt.Errorf("addfeed command requires the name of the feed and URL of the feed")
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("addfeed command requires the URL of the feed (optionally preceded by its name)")
 	}
 
-	feedName := cmd.args[0]
-	feedURL := cmd.args[1]
+	// Accept both "addfeed <name> <url>" and "addfeed <url>"
+	feedName := ""
+	pageURL := cmd.args[0]
+	if len(cmd.args) >= 2 {
+		feedName = cmd.args[0]
+		pageURL = cmd.args[1]
+	}
 
-	// Check if the feed already exists using a combination of feed name and user UUID
-	existingFeed, err := s.db.GetFeed(context.Background(), database.GetFeedParams{
-		Name:   feedName,
-		UserID: userUUID,
-	})
-	if err == nil && existingFeed == feedName {
-		return fmt.Errorf("feed '%s' already exists", feedName)
+	// Resolve website URLs to the feed they advertise and make sure it parses
+	feedURL, rssFeed, err := discoverFeed(context.Background(), s.fetcher, pageURL)
+	if err != nil {
+		return fmt.Errorf("failed to find a feed at '%s': %v", pageURL, err)
 	}
 
-	if err != nil && err.Error() != "sql: no rows in result set" {
-		// Handle database errors except "no rows found"
+	// Feeds that moved are known by their previous URLs too
+	existing, err := s.db.GetFeedNamebyURL(context.Background(), feedURL)
+	if err == nil {
+		return fmt.Errorf("feed '%s' already exists as '%s', use follow to subscribe to it", feedURL, existing.Name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("failed to check existing feed: %v", err)
 	}
 
+	if feedName == "" {
+		// Default to the channel title, made unique across all feeds
+		feedName, err = uniqueFeedName(s, feedTitle(rssFeed, feedURL))
+		if err != nil {
+			return err
+		}
+	} else {
+		// Check if the feed already exists using a combination of feed name and user UUID
+		existingFeed, err := s.db.GetFeed(context.Background(), database.GetFeedParams{
+			Name:   feedName,
+			UserID: userUUID,
+		})
+		if err == nil && existingFeed == feedName {
+			return fmt.Errorf("feed '%s' already exists", feedName)
+		}
+
+		if err != nil && err.Error() != "sql: no rows in result set" {
+			// Handle database errors except "no rows found"
+			return fmt.Errorf("failed to check existing feed: %v", err)
+		}
+	}
+
 	// Add the new feed
 	now := time.Now()
 	feedID := uuid.New()
@@ -437,6 +1278,11 @@ func handlerAddFeed(s *state, cmd command, userUUID uuid.UUID) error {
 		return fmt.Errorf("failed to create feed entry: %v", err)
 	}
 
+	err = saveFeedMetadata(context.Background(), s, feedID, rssFeed)
+	if err != nil {
+		return err
+	}
+
 	// Add the new feed in feed_followed
 	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		ID:        uuid.New(),
@@ -449,10 +1295,39 @@ func handlerAddFeed(s *state, cmd command, userUUID uuid.UUID) error {
 		return fmt.Errorf("failed to follow feed: %v", err)
 	}
 
-	fmt.Printf("Feed '%s' successfully added.\n", feedName)
+	fmt.Printf("Feed '%s' ('%s') successfully added.\n", feedName, feedURL)
 	return nil
 }
 
+// feedTitle returns the title to name a feed by, falling back to the host
+// name for feeds without a channel title.
+func feedTitle(rssFeed *RSSFeed, feedURL string) string {
+	title := strings.Join(strings.Fields(rssFeed.Channel.Title), " ")
+	if title != "" {
+		return title
+	}
+	if parsed, err := url.Parse(feedURL); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return feedURL
+}
+
+// uniqueFeedName appends a counter to name until it no longer clashes with
+// the name of an existing feed.
+func uniqueFeedName(s *state, name string) (string, error) {
+	candidate := name
+	for i := 2; ; i++ {
+		exists, err := s.db.FeedNameExists(context.Background(), candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check existing feed: %v", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s (%d)", name, i)
+	}
+}
+
 func handlerListFeeds(s *state, cmd command) error {
 	feedList, err := s.db.GetAllFeeds(context.Background())
 	if err != nil {
@@ -461,11 +1336,29 @@ func handlerListFeeds(s *state, cmd command) error {
 	fmt.Println("Feed name, URL, User Name")
 	for _, feedname := range feedList {
 		fmt.Printf("'%s', '%s', '%s'\n", feedname.Name, feedname.Url, feedname.Name_2)
+		printFeedDetail("Site", feedname.SiteLink)
+		printFeedDetail("Description", feedname.Description)
+		printFeedDetail("Language", feedname.Language)
+		printFeedDetail("Image", feedname.ImageUrl)
+		printFeedDetail("Generator", feedname.Generator)
+		if !feedname.Active {
+			fmt.Println("    Inactive: the feed is gone and is no longer fetched")
+		}
+		if feedname.ConsecutiveFailures > 0 {
+			fmt.Printf("    Failing: %d consecutive failures, last error: %s\n", feedname.ConsecutiveFailures, feedname.LastError.String)
+		}
 	}
 
 	return nil
 }
 
+// printFeedDetail prints an optional feed attribute below its feed line.
+func printFeedDetail(label string, value sql.NullString) {
+	if value.Valid {
+		fmt.Printf("    %s: %s\n", label, value.String)
+	}
+}
+
 func handlerFollowFeeds(s *state, cmd command, userUUID uuid.UUID) error {
 	if len(cmd.args) < 1 {
 		return fmt.Errorf("follow command requires the URL of the feed")
@@ -535,6 +1428,139 @@ func handlerUnfollowFeeds(s *state, cmd command, userUUID uuid.UUID) error {
 	return nil
 }
 
+func handlerFetches(s *state, cmd command) error {
+	// Accept "fetches" for statistics of all feeds and "fetches <url> [limit]"
+	// for the history of one feed
+	feedURL := ""
+	limit := 20 // Default limit
+	for _, arg := range cmd.args {
+		parsedLimit, err := strconv.Atoi(arg)
+		if err != nil {
+			feedURL = arg
+			continue
+		}
+		limit = parsedLimit
+	}
+
+	if feedURL == "" {
+		return printFetchStats(s)
+	}
+
+	feedNameAndID, err := s.db.GetFeedNamebyURL(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feed name for url, consider adding the feed first ...: %v", err)
+	}
+
+	fetches, err := s.db.GetFeedFetches(context.Background(), database.GetFeedFetchesParams{
+		FeedID: feedNameAndID.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch fetch history: %v", err)
+	}
+
+	if len(fetches) == 0 {
+		fmt.Printf("Feed '%s' has not been fetched yet.\n", feedNameAndID.Name)
+		return nil
+	}
+
+	fmt.Printf("Latest fetches of '%s':\n", feedNameAndID.Name)
+	for _, fetch := range fetches {
+		status := "-"
+		if fetch.StatusCode.Valid {
+			status = strconv.Itoa(int(fetch.StatusCode.Int32))
+		}
+		fmt.Printf("%s  %s  %6dms  ", fetch.StartedAt.Format("2006-01-02 15:04:05"), status, fetch.DurationMs)
+		if fetch.Error.Valid {
+			fmt.Printf("error: %s\n", fetch.Error.String)
+		} else {
+			fmt.Printf("%d new, %d updated\n", fetch.NewPosts, fetch.UpdatedPosts)
+		}
+	}
+
+	return nil
+}
+
+// printFetchStats prints the fetch count and failure rate of every feed over
+// the last 30 days.
+func printFetchStats(s *state) error {
+	stats, err := s.db.GetFeedFetchStats(context.Background(), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		return fmt.Errorf("failed to fetch fetch statistics: %v", err)
+	}
+
+	if len(stats) == 0 {
+		fmt.Println("No fetches in the last 30 days.")
+		return nil
+	}
+
+	fmt.Println("Fetches in the last 30 days:")
+	for _, stat := range stats {
+		failureRate := float64(stat.Failures) / float64(stat.Fetches) * 100
+		fmt.Printf("'%s', '%s'\n", stat.Name, stat.Url)
+		fmt.Printf("    %d fetches, %d failed (%.1f%%), %dms on average, %d new posts, last fetched %s\n",
+			stat.Fetches, stat.Failures, failureRate, stat.AvgDurationMs, stat.NewPosts,
+			stat.LastFetchedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	return nil
+}
+
+func handlerDownload(s *state, cmd command, userUUID uuid.UUID) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("download command requires a target directory")
+	}
+	dir := cmd.args[0]
+
+	limit := 10 // Default limit
+	if len(cmd.args) > 1 {
+		parsedLimit, err := strconv.Atoi(cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("invalid limit parameter: %v", err)
+		}
+		limit = parsedLimit
+	}
+
+	enclosures, err := s.db.GetEnclosuresForUser(context.Background(), database.GetEnclosuresForUserParams{
+		UserID: userUUID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch enclosures: %v", err)
+	}
+
+	if len(enclosures) == 0 {
+		fmt.Println("No enclosures found.")
+		return nil
+	}
+
+	failed := 0
+	for _, enclosure := range enclosures {
+		target, err := enclosurePath(dir, enclosure.Name, enclosure.Url)
+		if err != nil {
+			fmt.Printf("Failed to download '%s' (%s): %v\n", enclosure.Title, enclosure.Url, err)
+			failed++
+			continue
+		}
+		downloaded, err := downloadEnclosure(context.Background(), s.fetcher, enclosure.Url, target)
+		if err != nil {
+			fmt.Printf("Failed to download '%s' (%s): %v\n", enclosure.Title, enclosure.Url, err)
+			failed++
+			continue
+		}
+		if downloaded {
+			fmt.Printf("Downloaded '%s' to %s\n", enclosure.Title, target)
+		} else {
+			fmt.Printf("Already downloaded '%s' to %s\n", enclosure.Title, target)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(enclosures))
+	}
+	return nil
+}
+
 func main() {
 	// Load the configuration
 	cfg, err := config.Read()
@@ -552,9 +1578,17 @@ func main() {
 	// Initialize database queries
 	dbQueries := database.New(db)
 
+	// Set up the HTTP client shared by all fetches
+	feedFetcher, err := newFetcher(cfg.Fetch)
+	if err != nil {
+		log.Fatalf("Failed to configure fetching: %v", err)
+	}
+
 	appState := &state{
-		config: &cfg,
-		db:     dbQueries,
+		config:  &cfg,
+		db:      dbQueries,
+		sqlDB:   db,
+		fetcher: feedFetcher,
 	}
 
 	// Initialize the commands
@@ -570,6 +1604,8 @@ func main() {
 	cmds.register("following", middlewareLoggedIn(handlerFollowingFeeds))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollowFeeds))
 	cmds.register("browse", handlerBrowse)
+	cmds.register("download", middlewareLoggedIn(handlerDownload))
+	cmds.register("fetches", handlerFetches)
 
 	// Parse the command-line arguments
 	if len(os.Args) < 2 {
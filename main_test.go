@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// decodeTestFeed decodes a feed document from testdata as if it had been
+// fetched from https://example.com/<file>.
+func decodeTestFeed(t *testing.T, file, contentType string) *RSSFeed {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := decodeFeed(&fetchResponse{
+		Body:        body,
+		ContentType: contentType,
+		URL:         "https://example.com/" + file,
+	})
+	if err != nil {
+		t.Fatalf("decodeFeed returned error: %v", err)
+	}
+	return feed
+}
+
+// checkFeedItem compares the fields of a decoded item that are stored with
+// its post.
+func checkFeedItem(t *testing.T, got, want RSSItem) {
+	t.Helper()
+	if got.Title != want.Title {
+		t.Errorf("title = %q, want %q", got.Title, want.Title)
+	}
+	if got.Link != want.Link {
+		t.Errorf("link = %q, want %q", got.Link, want.Link)
+	}
+	if got.GUID.Value != want.GUID.Value {
+		t.Errorf("guid = %q, want %q", got.GUID.Value, want.GUID.Value)
+	}
+	if got.Description != want.Description {
+		t.Errorf("description = %q, want %q", got.Description, want.Description)
+	}
+	if got.Content != want.Content {
+		t.Errorf("content = %q, want %q", got.Content, want.Content)
+	}
+	if got.PubDate != want.PubDate {
+		t.Errorf("pubDate = %q, want %q", got.PubDate, want.PubDate)
+	}
+	if !slices.Equal(got.Authors, want.Authors) {
+		t.Errorf("authors = %q, want %q", got.Authors, want.Authors)
+	}
+	if !slices.Equal(got.Categories, want.Categories) {
+		t.Errorf("categories = %q, want %q", got.Categories, want.Categories)
+	}
+	if !slices.Equal(got.Enclosures, want.Enclosures) {
+		t.Errorf("enclosures = %+v, want %+v", got.Enclosures, want.Enclosures)
+	}
+}
+
+func TestDecodeRSSFeed(t *testing.T) {
+	feed := decodeTestFeed(t, "rss.xml", "application/rss+xml")
+
+	if feed.Channel.Title != "Example & Co" {
+		t.Errorf("channel title = %q, want %q", feed.Channel.Title, "Example & Co")
+	}
+	if feed.Channel.Link != "https://example.com/" {
+		t.Errorf("channel link = %q, want %q", feed.Channel.Link, "https://example.com/")
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+
+	// Escaped code in content:encoded stays escaped
+	checkFeedItem(t, feed.Channel.Item[0], RSSItem{
+		Title:       "Escaping in <pre> blocks",
+		Link:        "https://example.com/posts/escaping",
+		GUID:        RSSGUID{Value: "post-1"},
+		Description: "A post about escaping",
+		Content:     "<pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>",
+		PubDate:     "Mon, 2 Sep 2024 10:30:00 GMT",
+		Authors:     []string{"Jane Doe"},
+		Categories:  []string{"go"},
+		Enclosures:  []RSSEnclosure{{URL: "https://example.com/episode.mp3", Type: "audio/mpeg", Length: "1234"}},
+	})
+	checkFeedItem(t, feed.Channel.Item[1], RSSItem{
+		Title:       "Second post",
+		Link:        "https://example.com/posts/second",
+		Description: "<p>Second</p>",
+		PubDate:     "Tue, 3 Sep 2024 08:00:00 +0200",
+	})
+}
+
+func TestParseFeedDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 EST", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04 +0000", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"2 Jan 06 15:04:05 +0100", time.Date(2006, 1, 2, 14, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 +0000 (UTC)", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"  Mon,  2 Jan 2006   15:04:05 +0000 ", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05Z", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05+02:00", time.Date(2006, 1, 2, 13, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05.123Z", time.Date(2006, 1, 2, 15, 4, 5, 123000000, time.UTC)},
+		{"2006-01-02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"January 2, 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFeedDate(tt.input)
+		if err != nil {
+			t.Errorf("parseFeedDate(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseFeedDate(%q) = %v, want %v", tt.input, got.UTC(), tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "yesterday", "32 Jan 2006 15:04:05 +0000"} {
+		if got, err := parseFeedDate(input); err == nil {
+			t.Errorf("parseFeedDate(%q) = %v, want an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// RDFFeed is an RSS 1.0 (RDF Site Summary) document. Unlike RSS 2.0 the
+// items are siblings of the channel rather than children of it.
+type RDFFeed struct {
+	Base    string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"http://purl.org/dc/elements/1.1/ language"`
+		// The syndication module started out in RSS 1.0
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Image struct {
+		URL string `xml:"url"`
+	} `xml:"image"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	Base        string   `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creators    []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	DCDesc      string   `xml:"http://purl.org/dc/elements/1.1/ description"`
+}
+
+// parseRDFFeed decodes an RSS 1.0 document, including the Dublin Core
+// elements it relies on for dates and authors, and maps it onto the RSSFeed
+// model.
+func parseRDFFeed(body []byte) (*RSSFeed, error) {
+	var rdf RDFFeed
+	err := xml.Unmarshal(body, &rdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rdf feed: %w", err)
+	}
+
+	var feed RSSFeed
+	feed.Base = rdf.Base
+	feed.Channel.Title = strings.TrimSpace(rdf.Channel.Title)
+	feed.Channel.Link = strings.TrimSpace(rdf.Channel.Link)
+	feed.Channel.Description = strings.TrimSpace(rdf.Channel.Description)
+	feed.Channel.Language = strings.TrimSpace(rdf.Channel.Language)
+	feed.Channel.Image.URL = strings.TrimSpace(rdf.Image.URL)
+	feed.Channel.UpdatePeriod = strings.TrimSpace(rdf.Channel.UpdatePeriod)
+	feed.Channel.UpdateFrequency = strings.TrimSpace(rdf.Channel.UpdateFrequency)
+
+	for _, entry := range rdf.Items {
+		item := RSSItem{
+			Base:        entry.Base,
+			Title:       strings.TrimSpace(entry.Title),
+			Link:        strings.TrimSpace(entry.Link),
+			GUID:        RSSGUID{Value: strings.TrimSpace(entry.About), IsPermaLink: "false"},
+			Description: strings.TrimSpace(entry.Description),
+			Content:     strings.TrimSpace(entry.Content),
+			PubDate:     strings.TrimSpace(entry.Date),
+			Authors:     trimAll(entry.Creators),
+			Categories:  trimAll(entry.Subjects),
+		}
+
+		// rdf:about is required to be the item URI, so it doubles as a link
+		// for the few feeds that leave <link> out.
+		if item.Link == "" {
+			item.Link = strings.TrimSpace(entry.About)
+		}
+		if item.Description == "" {
+			item.Description = strings.TrimSpace(entry.DCDesc)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
+
+// trimAll trims every value and drops the ones left empty.
+func trimAll(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
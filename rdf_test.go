@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDecodeRDFFeed(t *testing.T) {
+	feed := decodeTestFeed(t, "rdf.xml", "application/rdf+xml")
+
+	if feed.Channel.Title != "Example RDF" {
+		t.Errorf("channel title = %q, want %q", feed.Channel.Title, "Example RDF")
+	}
+	if feed.Channel.Link != "https://example.com/" {
+		t.Errorf("channel link = %q, want %q", feed.Channel.Link, "https://example.com/")
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+
+	// RSS 1.0 items have no guid, their rdf:about identifies them
+	checkFeedItem(t, feed.Channel.Item[0], RSSItem{
+		Title:       "An RSS 1.0 post",
+		Link:        "https://example.com/posts/rdf",
+		GUID:        RSSGUID{Value: "https://example.com/posts/rdf"},
+		Description: "From RDF",
+		PubDate:     "2024-09-02T10:30:00+02:00",
+		Authors:     []string{"Jane Doe"},
+	})
+}
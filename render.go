@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const defaultRenderWidth = 80
+
+// htmlRenderer turns post HTML into wrapped plain text for the terminal.
+// Paragraph-like elements become blocks separated by blank lines, lists get
+// bullets or numbers, <pre> blocks keep their layout and links are listed
+// as numbered footnotes below the text.
+type htmlRenderer struct {
+	width     int
+	footnotes bool
+
+	lines     []string
+	inline    strings.Builder
+	needBlank bool
+	prefix    string // prepended to every line of the current block
+	marker    string // list marker for the first line of the next block
+	lists     []*listState
+	links     []string
+}
+
+type listState struct {
+	ordered bool
+	counter int
+}
+
+// terminalWidth returns the width to wrap text at, from $COLUMNS when set.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 20 {
+		return columns
+	}
+	return defaultRenderWidth
+}
+
+// renderHTML converts an HTML fragment into readable, wrapped terminal text.
+func renderHTML(source string, width int) string {
+	r := &htmlRenderer{width: width, footnotes: true}
+	return r.render(source)
+}
+
+// renderSummary returns the first maxChars characters of the text of an HTML
+// fragment on a single line, cut at a word boundary.
+func renderSummary(source string, maxChars int) string {
+	r := &htmlRenderer{width: 1 << 30}
+	text := strings.Join(strings.Fields(r.render(source)), " ")
+	if utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+
+	runes := []rune(text)[:maxChars]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > maxChars/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+func (r *htmlRenderer) render(source string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(source), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		// Not worth failing browse over, show the source instead
+		return source
+	}
+
+	for _, n := range nodes {
+		r.walk(n)
+	}
+	r.flush()
+
+	text := strings.Join(r.lines, "\n")
+	if len(r.links) > 0 {
+		var footer strings.Builder
+		footer.WriteString("\n\n")
+		for i, link := range r.links {
+			fmt.Fprintf(&footer, "[%d] %s\n", i+1, link)
+		}
+		text += strings.TrimRight(footer.String(), "\n")
+	}
+	return text
+}
+
+func (r *htmlRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.inline.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		r.walkChildren(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Iframe, atom.Svg:
+		return
+	case atom.Br:
+		r.inline.WriteString("\n")
+	case atom.Hr:
+		r.block(func() {
+			r.inline.WriteString(strings.Repeat("-", min(r.width, 40)))
+		})
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		r.block(func() {
+			r.inline.WriteString(strings.Repeat("#", level) + " ")
+			r.walkChildren(n)
+		})
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Figure, atom.Figcaption, atom.Table, atom.Dl, atom.Details, atom.Summary:
+		r.block(func() { r.walkChildren(n) })
+	case atom.Tr, atom.Dt, atom.Dd:
+		r.flush()
+		r.walkChildren(n)
+		r.flush()
+	case atom.Td, atom.Th:
+		r.walkChildren(n)
+		r.inline.WriteString("  ")
+	case atom.Blockquote:
+		r.block(func() {
+			saved := r.prefix
+			r.prefix += "> "
+			r.walkChildren(n)
+			r.flush()
+			r.prefix = saved
+		})
+	case atom.Pre:
+		r.block(func() { r.preformatted(n) })
+	case atom.Ul, atom.Ol:
+		r.list(n)
+	case atom.Li:
+		r.listItem(n)
+	case atom.Code, atom.Kbd, atom.Samp:
+		r.inline.WriteString("`")
+		r.walkChildren(n)
+		r.inline.WriteString("`")
+	case atom.A:
+		r.walkChildren(n)
+		r.footnote(htmlAttr(n, "href"))
+	case atom.Img:
+		alt := strings.TrimSpace(htmlAttr(n, "alt"))
+		if alt != "" {
+			r.inline.WriteString("[image: " + alt + "]")
+		} else {
+			r.inline.WriteString("[image]")
+		}
+	default:
+		r.walkChildren(n)
+	}
+}
+
+func (r *htmlRenderer) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// block renders a block level element separated by blank lines from the
+// text around it.
+func (r *htmlRenderer) block(render func()) {
+	r.flush()
+	r.needBlank = true
+	render()
+	r.flush()
+	r.needBlank = true
+}
+
+func (r *htmlRenderer) list(n *html.Node) {
+	r.flush()
+	nested := len(r.lists) > 0
+	if !nested {
+		r.needBlank = true
+	}
+
+	saved := r.prefix
+	if nested {
+		r.prefix += "  "
+	}
+	r.lists = append(r.lists, &listState{ordered: n.DataAtom == atom.Ol})
+	r.walkChildren(n)
+	r.flush()
+	r.lists = r.lists[:len(r.lists)-1]
+	r.prefix = saved
+
+	if !nested {
+		r.needBlank = true
+	}
+}
+
+func (r *htmlRenderer) listItem(n *html.Node) {
+	r.flush()
+	marker := "• "
+	if len(r.lists) > 0 {
+		current := r.lists[len(r.lists)-1]
+		current.counter++
+		if current.ordered {
+			marker = strconv.Itoa(current.counter) + ". "
+		}
+	}
+	r.marker = marker
+	r.walkChildren(n)
+	r.flush()
+	r.marker = ""
+}
+
+// preformatted writes the text of a <pre> element indented and unwrapped.
+func (r *htmlRenderer) preformatted(n *html.Node) {
+	var text strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			text.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	lines := strings.Split(strings.Trim(text.String(), "\n"), "\n")
+	if r.needBlank && len(r.lines) > 0 {
+		r.lines = append(r.lines, "")
+	}
+	for _, line := range lines {
+		r.lines = append(r.lines, strings.TrimRight(r.prefix+"    "+strings.ReplaceAll(line, "\t", "    "), " "))
+	}
+	r.needBlank = false
+}
+
+// footnote records a link target and marks it in the text.
+func (r *htmlRenderer) footnote(href string) {
+	href = strings.TrimSpace(href)
+	if !r.footnotes || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return
+	}
+
+	index := -1
+	for i, link := range r.links {
+		if link == href {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		r.links = append(r.links, href)
+		index = len(r.links) - 1
+	}
+	fmt.Fprintf(&r.inline, "[%d]", index+1)
+}
+
+// flush wraps the pending inline text into lines of the current block.
+func (r *htmlRenderer) flush() {
+	pending := r.inline.String()
+	r.inline.Reset()
+
+	var segments []string
+	for _, segment := range strings.Split(pending, "\n") {
+		segments = append(segments, strings.Join(strings.Fields(segment), " "))
+	}
+	for len(segments) > 0 && segments[len(segments)-1] == "" {
+		segments = segments[:len(segments)-1]
+	}
+	if len(segments) == 0 || strings.Join(segments, "") == "" {
+		return
+	}
+
+	if r.needBlank && len(r.lines) > 0 && r.lines[len(r.lines)-1] != "" {
+		r.lines = append(r.lines, "")
+	}
+	r.needBlank = false
+
+	first := r.prefix + r.marker
+	rest := r.prefix + strings.Repeat(" ", utf8.RuneCountInString(r.marker))
+	available := max(r.width-utf8.RuneCountInString(first), 20)
+
+	lineNo := 0
+	for _, segment := range segments {
+		for _, line := range wrapText(segment, available) {
+			if lineNo == 0 {
+				r.lines = append(r.lines, first+line)
+			} else {
+				r.lines = append(r.lines, rest+line)
+			}
+			lineNo++
+		}
+	}
+	r.marker = ""
+}
+
+// wrapText breaks text into lines of at most width characters, splitting at
+// spaces. Words longer than a line are kept whole.
+func wrapText(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
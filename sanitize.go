@@ -0,0 +1,284 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags are kept when sanitizing post HTML, together with the
+// attributes each of them may keep. Tags that are neither allowed nor
+// dropped are unwrapped: the tag goes, its content stays.
+var allowedTags = map[string][]string{
+	"a":          {"href", "title"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"cite":       nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        nil,
+	"details":    nil,
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"samp":       nil,
+	"small":      nil,
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan"},
+	"thead":      nil,
+	"time":       {"datetime"},
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+// droppedTags are removed together with everything inside them.
+var droppedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"frame":    true,
+	"frameset": true,
+	"object":   true,
+	"embed":    true,
+	"applet":   true,
+	"form":     true,
+	"input":    true,
+	"button":   true,
+	"select":   true,
+	"textarea": true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"math":     true,
+	"link":     true,
+	"meta":     true,
+	"base":     true,
+}
+
+// urlAttributes hold URLs, which are checked for their scheme and cleaned of
+// tracking parameters.
+var urlAttributes = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+// trackerURLs are host and path prefixes of known tracking images.
+var trackerURLs = []string{
+	"feeds.feedburner.com/~r/",
+	"feedproxy.google.com/~r/",
+	"pixel.wp.com/",
+	"stats.wordpress.com/",
+	"feeds.wordpress.com/1.0/",
+	"www.google-analytics.com/",
+	"pixel.quantserve.com/",
+	"pi.feedsportal.com/",
+	"da.feedsportal.com/",
+	"ad.doubleclick.net/",
+	"api.follow.it/track",
+	"ir-na.amazon-adsystem.com/",
+	"www.assoc-amazon.com/",
+}
+
+// sanitizeHTML rewrites post HTML so that only safe tags and attributes are
+// left. Scripts, frames, event handlers, inline styles and tracking images
+// are removed and utm_* parameters are stripped from links.
+func sanitizeHTML(source string) string {
+	if strings.TrimSpace(source) == "" {
+		return source
+	}
+
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(source), container)
+	if err != nil {
+		// Keep the text but nothing that could be interpreted as markup
+		return html.EscapeString(source)
+	}
+	for _, n := range nodes {
+		container.AppendChild(n)
+	}
+	sanitizeChildren(container)
+
+	var out strings.Builder
+	for c := container.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&out, c); err != nil {
+			return html.EscapeString(source)
+		}
+	}
+	return out.String()
+}
+
+// textToHTML turns plain text into HTML paragraphs, escaping the characters
+// that would otherwise be read as markup. Blank lines separate paragraphs
+// and single line breaks are kept as <br>.
+func textToHTML(text string) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return ""
+	}
+
+	var out strings.Builder
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(strings.TrimSpace(line))
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return out.String()
+}
+
+func sanitizeChildren(parent *html.Node) {
+	for c := parent.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch c.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			tag := strings.ToLower(c.Data)
+			allowedAttrs, allowed := allowedTags[tag]
+			switch {
+			case droppedTags[tag]:
+				parent.RemoveChild(c)
+			case !allowed:
+				// Unwrap: sanitize the content, then move it in place of the tag
+				sanitizeChildren(c)
+				for gc := c.FirstChild; gc != nil; {
+					gnext := gc.NextSibling
+					c.RemoveChild(gc)
+					parent.InsertBefore(gc, c)
+					gc = gnext
+				}
+				parent.RemoveChild(c)
+			default:
+				c.Attr = sanitizeAttributes(c.Attr, allowedAttrs)
+				if tag == "img" && (htmlAttr(c, "src") == "" || isTrackingImage(c)) {
+					parent.RemoveChild(c)
+					break
+				}
+				sanitizeChildren(c)
+			}
+		default:
+			// Comments, doctypes and the like
+			parent.RemoveChild(c)
+		}
+
+		c = next
+	}
+}
+
+// sanitizeAttributes keeps the allowed attributes of a tag and drops URL
+// attributes with unsafe schemes.
+func sanitizeAttributes(attrs []html.Attribute, allowed []string) []html.Attribute {
+	var kept []html.Attribute
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || !slices.Contains(allowed, key) {
+			continue
+		}
+		if urlAttributes[key] {
+			cleaned, ok := cleanURL(attr.Val)
+			if !ok {
+				continue
+			}
+			attr.Val = cleaned
+		}
+		attr.Key = key
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// cleanURL checks that a URL is relative or uses a safe scheme and strips
+// its utm_* query parameters. It reports false for URLs that must go.
+func cleanURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto":
+	default:
+		return "", false
+	}
+
+	query := parsed.Query()
+	stripped := false
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return raw, true
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), true
+}
+
+// isTrackingImage reports whether an <img> is a tracking pixel: a 1x1 (or
+// smaller) image or one served by a known tracker.
+func isTrackingImage(img *html.Node) bool {
+	if isTinyDimension(htmlAttr(img, "width")) || isTinyDimension(htmlAttr(img, "height")) {
+		return true
+	}
+
+	src := strings.ToLower(htmlAttr(img, "src"))
+	src = strings.TrimPrefix(strings.TrimPrefix(src, "https:"), "http:")
+	src = strings.TrimPrefix(src, "//")
+	for _, tracker := range trackerURLs {
+		if strings.HasPrefix(src, tracker) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTinyDimension(value string) bool {
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px"))
+	return err == nil && size <= 1
+}
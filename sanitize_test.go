@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "Hello, world", "Hello, world"},
+		{"empty", "", ""},
+		{"allowed markup", "<p>Hello <strong>world</strong></p>", "<p>Hello <strong>world</strong></p>"},
+		{"script", `<p>Hi</p><script>alert(1)</script>`, "<p>Hi</p>"},
+		{"iframe", `<iframe src="https://evil.example/"></iframe><p>Hi</p>`, "<p>Hi</p>"},
+		{"style", `<style>p { color: red }</style><p>Hi</p>`, "<p>Hi</p>"},
+		{"event handler", `<p onclick="alert(1)">Hi</p>`, "<p>Hi</p>"},
+		{"inline style", `<p style="color: red">Hi</p>`, "<p>Hi</p>"},
+		{"javascript url", `<a href="javascript:alert(1)">Hi</a>`, "<a>Hi</a>"},
+		{"javascript url with spaces and case", `<a href=" JavaScript:alert(1)">Hi</a>`, "<a>Hi</a>"},
+		{"data url image", `<img src="data:image/png;base64,AAAA" alt="x">`, ""},
+		{"safe link", `<a href="https://example.com/" title="Example">Hi</a>`, `<a href="https://example.com/" title="Example">Hi</a>`},
+		{"utm parameters", `<a href="https://example.com/?utm_source=feed&utm_medium=rss&id=7">Hi</a>`, `<a href="https://example.com/?id=7">Hi</a>`},
+		{"tracking pixel by size", `<p>Hi<img src="https://example.com/p.gif" width="1" height="1"></p>`, "<p>Hi</p>"},
+		{"tracking pixel by host", `<img src="https://feeds.feedburner.com/~r/example/~4/abc">`, ""},
+		{"image", `<img src="https://example.com/a.png" alt="A" onerror="alert(1)">`, `<img src="https://example.com/a.png" alt="A"/>`},
+		{"unknown tag is unwrapped", `<article><p>Hi</p></article>`, "<p>Hi</p>"},
+		{"comment", `<p>Hi<!-- secret --></p>`, "<p>Hi</p>"},
+		{"escaped code is kept", "<pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>", "<pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeHTML(tt.input); got != tt.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextToHTML(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"a < b && c", "<p>a &lt; b &amp;&amp; c</p>"},
+		{"one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"one\r\n\r\n\r\ntwo\r\n", "<p>one</p><p>two</p>"},
+	}
+
+	for _, tt := range tests {
+		if got := textToHTML(tt.input); got != tt.want {
+			t.Errorf("textToHTML(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
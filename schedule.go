@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1729prashant/blog-aggregator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	feedRetryBaseDelay = time.Minute
+	feedRetryMaxDelay  = 24 * time.Hour
+)
+
+// feedRetryDelay returns how long to wait before fetching a feed again after
+// its nth consecutive failure. The delay doubles with every failure up to
+// feedRetryMaxDelay, and is picked at random from its upper half so that
+// feeds that broke together don't all come back at the same moment.
+func feedRetryDelay(failures int) time.Duration {
+	// Doubling step by step stops at the cap, where shifting by the
+	// failure count would overflow for long failing feeds
+	delay := feedRetryBaseDelay
+	for i := 1; i < failures && delay < feedRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, feedRetryMaxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// delaySeconds converts a delay to the whole seconds that the database adds
+// to its clock to schedule the next fetch of a feed, rounding up.
+func delaySeconds(delay time.Duration) int32 {
+	return int32(math.Ceil(max(delay, 0).Seconds()))
+}
+
+// failFeed counts a failed fetch of a feed, stores the error and schedules
+// the next attempt with backoff. It returns fetchErr so that the caller still
+// reports it.
+func failFeed(ctx context.Context, s *state, out io.Writer, feedID uuid.UUID, fetchErr error) error {
+	failures, err := s.db.GetFeedFailureCount(ctx, feedID)
+	if err != nil {
+		return fmt.Errorf("%w (failed to get failure count: %v)", fetchErr, err)
+	}
+
+	now := time.Now()
+	delay := feedRetryDelay(int(failures) + 1)
+	err = s.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		LastError:    nullString(fetchErr.Error()),
+		RetrySeconds: delaySeconds(delay),
+		UpdatedAt:    now,
+		ID:           feedID,
+	})
+	if err != nil {
+		return fmt.Errorf("%w (failed to record feed failure: %v)", fetchErr, err)
+	}
+
+	fmt.Fprintf(out, "Feed failed %d time(s) in a row, retrying in %v\n", failures+1, delay.Round(time.Second))
+	return fetchErr
+}
+
+const (
+	defaultMinRefreshInterval = 15 * time.Minute
+	defaultMaxRefreshInterval = 24 * time.Hour
+	// defaultRefreshInterval is used for feeds with too few posts to tell
+	// how often they publish
+	defaultRefreshInterval = time.Hour
+	// recentPostCount is the number of latest posts the posting frequency
+	// of a feed is estimated from
+	recentPostCount = 20
+)
+
+// updatePeriods are the values of sy:updatePeriod.
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// refreshPolicy bounds the interval between two fetches of a feed.
+type refreshPolicy struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+// scheduleNextFetch picks when a feed that was just fetched should be fetched
+// again, from how often it published lately, the hints the feed gives about
+// its update schedule and the cache lifetime of the response.
+func scheduleNextFetch(ctx context.Context, s *state, feedID uuid.UUID, res *fetchResponse, policy refreshPolicy) (time.Time, error) {
+	hints, err := s.db.GetFeedRefreshHints(ctx, feedID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get refresh hints: %w", err)
+	}
+
+	postTimes, err := s.db.GetRecentPostTimes(ctx, database.GetRecentPostTimesParams{
+		FeedID: feedID,
+		Limit:  recentPostCount,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get recent posts: %w", err)
+	}
+
+	interval := refreshInterval(postTimes, hints, res.CacheLifetime, policy)
+	return nextFetchTime(time.Now(), interval, hints.SkipHours, hints.SkipDays), nil
+}
+
+// refreshInterval returns how long to wait between fetches of a feed. A feed
+// is fetched about twice for every post it publishes on average, but never
+// sooner than its ttl, its sy:updatePeriod or the cache lifetime of the
+// response allow, and always within the bounds of the policy.
+func refreshInterval(postTimes []time.Time, hints database.GetFeedRefreshHintsRow, cacheLifetime time.Duration, policy refreshPolicy) time.Duration {
+	interval := defaultRefreshInterval
+	if len(postTimes) >= 2 {
+		// Post times come newest first
+		span := postTimes[0].Sub(postTimes[len(postTimes)-1])
+		interval = span / time.Duration(len(postTimes)-1) / 2
+	}
+
+	if hints.TtlMinutes.Valid {
+		interval = max(interval, time.Duration(hints.TtlMinutes.Int32)*time.Minute)
+	}
+	if period, ok := updatePeriods[hints.UpdatePeriod.String]; ok {
+		frequency := int32(1)
+		if hints.UpdateFrequency.Valid {
+			frequency = hints.UpdateFrequency.Int32
+		}
+		interval = max(interval, period/time.Duration(frequency))
+	}
+	interval = max(interval, cacheLifetime)
+
+	return min(max(interval, policy.MinInterval), policy.MaxInterval)
+}
+
+// nextFetchTime adds interval to now and moves the result past the hours
+// (0-23, GMT) and days the feed asks not to be fetched in.
+func nextFetchTime(now time.Time, interval time.Duration, skipHours []int32, skipDays []string) time.Time {
+	next := now.Add(interval)
+
+	skipped := func(t time.Time) bool {
+		t = t.UTC()
+		return slices.Contains(skipHours, int32(t.Hour())) || slices.Contains(skipDays, t.Weekday().String())
+	}
+	// A week's worth of hours covers every combination; a feed that skips
+	// all of them is fetched anyway
+	for i := 0; i < 7*24 && skipped(next); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
+
+// positiveInt parses a feed value such as <ttl> that must be a positive number.
+func positiveInt(value string) sql.NullInt32 {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 || n > math.MaxInt32 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}
+}
+
+// parseSkipHours reads the hours of <skipHours>, where 24 is taken as midnight.
+func parseSkipHours(values []string) []int32 {
+	var hours []int32
+	for _, value := range values {
+		hour, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || hour < 0 || hour > 24 {
+			continue
+		}
+		hours = append(hours, int32(hour%24))
+	}
+	return hours
+}
+
+// parseSkipDays reads the days of <skipDays> as the names time.Weekday uses.
+func parseSkipDays(values []string) []string {
+	var days []string
+	for _, value := range values {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(value), day.String()) {
+				days = append(days, day.String())
+			}
+		}
+	}
+	return days
+}
+
+// parseUpdatePeriod reads sy:updatePeriod, leaving out unknown periods.
+func parseUpdatePeriod(value string) sql.NullString {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if _, ok := updatePeriods[value]; !ok {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: value, Valid: true}
+}
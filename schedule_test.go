@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/1729prashant/blog-aggregator/internal/database"
+)
+
+func TestFeedRetryDelay(t *testing.T) {
+	for failures := 0; failures <= 64; failures++ {
+		// The full delay before the random spread is applied
+		full := feedRetryMaxDelay
+		if failures < 12 {
+			full = min(feedRetryBaseDelay<<max(failures-1, 0), feedRetryMaxDelay)
+		}
+
+		for range 20 {
+			delay := feedRetryDelay(failures)
+			if delay <= 0 || delay > feedRetryMaxDelay {
+				t.Fatalf("feedRetryDelay(%d) = %v, want a delay in (0, %v]", failures, delay, feedRetryMaxDelay)
+			}
+			if delay < full/2 || delay > full {
+				t.Fatalf("feedRetryDelay(%d) = %v, want a delay in [%v, %v]", failures, delay, full/2, full)
+			}
+		}
+	}
+
+	if delay := feedRetryDelay(1); delay > time.Minute {
+		t.Errorf("feedRetryDelay(1) = %v, want at most a minute", delay)
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	policy := refreshPolicy{MinInterval: 15 * time.Minute, MaxInterval: 24 * time.Hour}
+	now := time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC)
+	// everyHours returns the times of count posts published every h hours,
+	// newest first
+	everyHours := func(h, count int) []time.Time {
+		var times []time.Time
+		for i := range count {
+			times = append(times, now.Add(-time.Duration(i*h)*time.Hour))
+		}
+		return times
+	}
+
+	tests := []struct {
+		name          string
+		postTimes     []time.Time
+		hints         database.GetFeedRefreshHintsRow
+		cacheLifetime time.Duration
+		want          time.Duration
+	}{
+		{"no posts", nil, database.GetFeedRefreshHintsRow{}, 0, defaultRefreshInterval},
+		{"one post", everyHours(1, 1), database.GetFeedRefreshHintsRow{}, 0, defaultRefreshInterval},
+		{"post every 4 hours", everyHours(4, 10), database.GetFeedRefreshHintsRow{}, 0, 2 * time.Hour},
+		{"post every 10 minutes", everyHours(0, 10), database.GetFeedRefreshHintsRow{}, 0, 15 * time.Minute},
+		{"post every week", everyHours(7*24, 5), database.GetFeedRefreshHintsRow{}, 0, 24 * time.Hour},
+		{
+			"ttl",
+			everyHours(1, 10),
+			database.GetFeedRefreshHintsRow{TtlMinutes: sql.NullInt32{Int32: 180, Valid: true}},
+			0,
+			3 * time.Hour,
+		},
+		{
+			"update period",
+			everyHours(1, 10),
+			database.GetFeedRefreshHintsRow{UpdatePeriod: sql.NullString{String: "daily", Valid: true}},
+			0,
+			24 * time.Hour,
+		},
+		{
+			"update period and frequency",
+			everyHours(1, 10),
+			database.GetFeedRefreshHintsRow{
+				UpdatePeriod:    sql.NullString{String: "daily", Valid: true},
+				UpdateFrequency: sql.NullInt32{Int32: 4, Valid: true},
+			},
+			0,
+			6 * time.Hour,
+		},
+		{"cache lifetime", everyHours(1, 10), database.GetFeedRefreshHintsRow{}, 5 * time.Hour, 5 * time.Hour},
+		{"cache lifetime above the maximum", everyHours(1, 10), database.GetFeedRefreshHintsRow{}, 48 * time.Hour, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := refreshInterval(tt.postTimes, tt.hints, tt.cacheLifetime, policy); got != tt.want {
+			t.Errorf("%s: refreshInterval = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextFetchTime(t *testing.T) {
+	// A Monday
+	now := time.Date(2024, 9, 2, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		skipHours []int32
+		skipDays  []string
+		want      time.Time
+	}{
+		{"no hints", time.Hour, nil, nil, now.Add(time.Hour)},
+		{"outside the skipped hours", time.Hour, []int32{3, 4}, nil, now.Add(time.Hour)},
+		{"skipped hours", time.Hour, []int32{11, 12}, nil, time.Date(2024, 9, 2, 13, 0, 0, 0, time.UTC)},
+		{"skipped day", 24 * time.Hour, nil, []string{"Tuesday"}, time.Date(2024, 9, 4, 0, 0, 0, 0, time.UTC)},
+		{"skipped day and hour", 24 * time.Hour, []int32{0}, []string{"Tuesday"}, time.Date(2024, 9, 4, 1, 0, 0, 0, time.UTC)},
+		{
+			"every hour skipped",
+			time.Hour,
+			[]int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23},
+			nil,
+			time.Date(2024, 9, 9, 11, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		if got := nextFetchTime(now, tt.interval, tt.skipHours, tt.skipDays); !got.Equal(tt.want) {
+			t.Errorf("%s: nextFetchTime = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
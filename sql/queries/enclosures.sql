@@ -0,0 +1,30 @@
+-- name: CreateEnclosure :exec
+INSERT INTO enclosures (id, created_at, post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (post_id, url) DO NOTHING;
+--
+
+
+-- name: DeletePostEnclosures :exec
+DELETE FROM enclosures WHERE post_id = $1;
+--
+
+
+-- name: GetEnclosuresForPost :many
+SELECT url, mime_type, length, duration_seconds
+FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at, url;
+--
+
+
+-- name: GetEnclosuresForUser :many
+SELECT f.name, p.title, e.url, e.mime_type, e.length
+FROM enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+ORDER BY COALESCE(p.published_at, p.first_seen_at) DESC
+LIMIT $2;
+--
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (id, feed_id, started_at, duration_ms, status_code, new_posts, updated_posts, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+--
+
+
+-- name: GetFeedFetches :many
+SELECT started_at, duration_ms, status_code, new_posts, updated_posts, error
+FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
+--
+
+
+-- name: GetFeedFetchStats :many
+SELECT f.name, f.url,
+    COUNT(*) AS fetches,
+    COUNT(*) FILTER (WHERE fe.error IS NOT NULL OR fe.status_code >= 400) AS failures,
+    AVG(fe.duration_ms)::BIGINT AS avg_duration_ms,
+    SUM(fe.new_posts)::BIGINT AS new_posts,
+    MAX(fe.started_at)::TIMESTAMP AS last_fetched_at
+FROM feed_fetches fe
+JOIN feeds f ON fe.feed_id = f.id
+WHERE fe.started_at >= $1
+GROUP BY f.id, f.name, f.url
+ORDER BY f.name;
+--
\ No newline at end of file
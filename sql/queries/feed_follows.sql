@@ -26,21 +26,10 @@ FROM feed_follows ff, feeds f, users u
 WHERE ff.feed_id = f.id 
 AND ff.user_id = u.id 
 AND u.name = $1
-AND f.url = $2;
+AND (f.url = $2 OR f.id IN (SELECT fu.feed_id FROM feed_urls fu WHERE fu.url = $2));
 --
 
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows WHERE feed_id = $1 AND user_id = $2;
---
-
-
--- name: GetNextFeedToFetch :one
-SELECT f.url
-FROM feed_follows ff, feeds f, users u 
-WHERE ff.feed_id = f.id 
-AND ff.user_id = u.id 
-AND u.name = $1
-ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1;
 --
\ No newline at end of file
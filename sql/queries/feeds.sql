@@ -19,7 +19,8 @@ SELECT name FROM feeds WHERE name = $1 AND user_id = $2 LIMIT 1;
 
 
 -- name: GetAllFeeds :many
-SELECT f.name, f.url, u.name
+SELECT f.name, f.url, u.name, f.site_link, f.description, f.language, f.image_url, f.generator, f.active,
+    f.consecutive_failures, f.last_error
 FROM feeds f, users u
 where u.id = f.user_id
 ORDER BY f.name;
@@ -27,12 +28,113 @@ ORDER BY f.name;
 
 
 -- name: GetFeedNamebyURL :one
-SELECT name, id FROM feeds WHERE url = $1 LIMIT 1;
+SELECT name, id FROM feeds
+WHERE url = $1
+OR id IN (SELECT feed_id FROM feed_urls WHERE feed_urls.url = $1)
+LIMIT 1;
 --
 
 
 
 -- name: MarkFeedFetched :exec
-UPDATE feeds set last_fetched_at = $1, updated_at = $2
+UPDATE feeds
+set last_fetched_at = sqlc.arg(last_fetched_at), updated_at = sqlc.arg(updated_at), etag = sqlc.arg(etag), last_modified = sqlc.arg(last_modified),
+    consecutive_failures = 0, last_error = NULL,
+    next_attempt_at = LOCALTIMESTAMP + sqlc.arg(next_attempt_seconds)::INTEGER * INTERVAL '1 second'
+WHERE id = sqlc.arg(id);
+--
+
+
+-- name: FeedNameExists :one
+SELECT EXISTS (SELECT 1 FROM feeds WHERE name = $1);
+--
+
+
+-- name: UpdateFeedMetadata :exec
+UPDATE feeds
+SET site_link = $1, description = $2, language = $3, image_url = $4, generator = $5, updated_at = $6,
+    ttl_minutes = $7, skip_hours = $8, skip_days = $9, update_period = $10, update_frequency = $11
+WHERE id = $12;
+--
+
+
+-- name: GetFeedCacheHeaders :one
+SELECT etag, last_modified FROM feeds WHERE id = $1;
+--
+
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds SET url = $1, updated_at = $2
 WHERE id = $3;
+--
+
+
+-- name: CreateFeedURL :exec
+INSERT INTO feed_urls (id, created_at, feed_id, url)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (url) DO NOTHING;
+--
+
+
+-- name: DeactivateFeed :exec
+UPDATE feeds SET active = FALSE, updated_at = $1
+WHERE id = $2;
+--
+
+
+-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1, last_error = sqlc.arg(last_error),
+    next_attempt_at = LOCALTIMESTAMP + sqlc.arg(retry_seconds)::INTEGER * INTERVAL '1 second', updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id);
+--
+
+
+-- name: GetFeedFailureCount :one
+SELECT consecutive_failures FROM feeds WHERE id = $1;
+--
+
+
+-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET lease_owner = sqlc.arg(owner),
+    lease_expires_at = LOCALTIMESTAMP + sqlc.arg(lease_seconds)::INTEGER * INTERVAL '1 second'
+WHERE id IN (
+    SELECT f.id
+    FROM feeds f
+    WHERE f.active
+    AND (f.next_attempt_at IS NULL OR f.next_attempt_at <= LOCALTIMESTAMP)
+    AND (f.lease_expires_at IS NULL OR f.lease_expires_at <= LOCALTIMESTAMP)
+    AND EXISTS (
+        SELECT 1
+        FROM feed_follows ff
+        JOIN users u ON ff.user_id = u.id
+        WHERE ff.feed_id = f.id
+        AND (sqlc.arg(all_users)::BOOLEAN OR u.name = ANY(sqlc.arg(user_names)::TEXT[]))
+    )
+    ORDER BY f.last_fetched_at NULLS FIRST
+    LIMIT sqlc.arg(max_feeds)
+    FOR UPDATE OF f SKIP LOCKED
+)
+RETURNING id, name, url;
+--
+
+
+-- name: ReleaseFeedLease :exec
+UPDATE feeds SET lease_owner = NULL, lease_expires_at = NULL
+WHERE id = $1 AND lease_owner = $2;
+--
+
+
+-- name: ExtendFeedLease :execrows
+UPDATE feeds
+SET lease_expires_at = LOCALTIMESTAMP + sqlc.arg(lease_seconds)::INTEGER * INTERVAL '1 second'
+WHERE id = sqlc.arg(id) AND lease_owner = sqlc.arg(owner);
+--
+
+
+-- name: GetFeedRefreshHints :one
+SELECT ttl_minutes, skip_hours, skip_days, update_period, update_frequency
+FROM feeds
+WHERE id = $1;
 --
\ No newline at end of file
@@ -1,4 +1,4 @@
--- name: CreatePost :one
+-- name: UpsertPost :one
 INSERT INTO posts (
     id,
     created_at,
@@ -7,20 +7,93 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    feed_id,
+    guid,
+    content,
+    content_hash,
+    first_seen_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (feed_id, (COALESCE(guid, url))) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = COALESCE(EXCLUDED.published_at, posts.published_at),
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash
+WHERE posts.content_hash <> EXCLUDED.content_hash
 RETURNING *;
 --
 
 
 -- name: GetPostsForUser :many
-SELECT f.name, p.title, p.url , p.description, p.published_at
+SELECT f.name, p.title, p.url , p.description, p.published_at, p.first_seen_at, p.content,
+    COALESCE((SELECT string_agg(pa.name, ', ' ORDER BY pa.name) FROM post_authors pa WHERE pa.post_id = p.id), '')::TEXT AS authors,
+    COALESCE((SELECT string_agg(pc.name, ', ' ORDER BY pc.name) FROM post_categories pc WHERE pc.post_id = p.id), '')::TEXT AS categories,
+    p.id
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
 WHERE ff.user_id = (
     SELECT u.id FROM users u WHERE u.name = $1
 )
-ORDER BY p.published_at DESC
+ORDER BY COALESCE(p.published_at, p.first_seen_at) DESC
 LIMIT $2;
---
\ No newline at end of file
+--
+
+
+-- name: CreatePostAuthor :exec
+INSERT INTO post_authors (id, created_at, post_id, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (post_id, name) DO NOTHING;
+--
+
+
+-- name: CreatePostCategory :exec
+INSERT INTO post_categories (id, created_at, post_id, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (post_id, name) DO NOTHING;
+--
+
+
+-- name: DeletePostAuthors :exec
+DELETE FROM post_authors WHERE post_id = $1;
+--
+
+
+-- name: DeletePostCategories :exec
+DELETE FROM post_categories WHERE post_id = $1;
+--
+
+
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+SELECT sqlc.arg(id)::UUID, sqlc.arg(created_at)::TIMESTAMP, p.id, p.title, p.url, p.description, p.content, p.content_hash
+FROM posts p
+WHERE p.feed_id = sqlc.arg(feed_id)::UUID
+AND COALESCE(p.guid, p.url) = sqlc.arg(post_key)::TEXT
+AND p.content_hash <> sqlc.arg(content_hash)::TEXT;
+--
+
+
+-- name: GetRecentPostTimes :many
+SELECT COALESCE(published_at, first_seen_at)::TIMESTAMP AS posted_at
+FROM posts
+WHERE feed_id = $1
+ORDER BY posted_at DESC
+LIMIT $2;
+--
+
+
+-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET guid = sqlc.arg(guid)::TEXT
+WHERE feed_id = sqlc.arg(feed_id)
+AND url = sqlc.arg(url)
+AND guid IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM posts p
+    WHERE p.feed_id = sqlc.arg(feed_id)
+    AND COALESCE(p.guid, p.url) = sqlc.arg(guid)::TEXT
+);
+--
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN guid TEXT,
+    ADD COLUMN content TEXT;
+
+CREATE TABLE post_authors (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (post_id, name)
+);
+
+CREATE TABLE post_categories (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (post_id, name)
+);
+
+-- +goose Down
+DROP TABLE post_categories;
+DROP TABLE post_authors;
+ALTER TABLE posts
+    DROP COLUMN guid,
+    DROP COLUMN content;
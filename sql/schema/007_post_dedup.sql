@@ -0,0 +1,11 @@
+-- +goose Up
+-- Posts are unique within a feed by their guid, or their url when they have
+-- no guid, instead of by url across every feed.
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+CREATE UNIQUE INDEX posts_feed_id_guid_key ON posts (feed_id, (COALESCE(guid, url)));
+
+-- +goose Down
+-- This fails once several feeds share a post url, which the Up migration
+-- allows. Remove those duplicates by hand before migrating down.
+DROP INDEX posts_feed_id_guid_key;
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
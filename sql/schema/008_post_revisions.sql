@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+
+-- Same fingerprint as postContentHash in main.go
+UPDATE posts
+SET content_hash = encode(sha256(convert_to(title || E'\n' || url || E'\n' || description || E'\n' || COALESCE(content, ''), 'UTF8')), 'hex');
+
+ALTER TABLE posts ALTER COLUMN content_hash SET NOT NULL;
+
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT NOT NULL,
+    content TEXT,
+    content_hash TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN content_hash;
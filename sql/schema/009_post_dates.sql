@@ -0,0 +1,12 @@
+-- +goose Up
+-- published_at is left NULL when a feed item has no usable date, first_seen_at
+-- records when the aggregator first stored the post.
+ALTER TABLE posts ADD COLUMN first_seen_at TIMESTAMP;
+UPDATE posts SET first_seen_at = created_at;
+ALTER TABLE posts ALTER COLUMN first_seen_at SET NOT NULL;
+ALTER TABLE posts ALTER COLUMN published_at DROP NOT NULL;
+
+-- +goose Down
+UPDATE posts SET published_at = first_seen_at WHERE published_at IS NULL;
+ALTER TABLE posts ALTER COLUMN published_at SET NOT NULL;
+ALTER TABLE posts DROP COLUMN first_seen_at;
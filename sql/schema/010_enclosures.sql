@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE enclosures (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    length BIGINT,
+    duration_seconds BIGINT,
+    UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE enclosures;
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE feed_urls (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    url TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feed_urls;
+
+ALTER TABLE feeds
+    DROP COLUMN active;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN lease_owner TEXT,
+    ADD COLUMN lease_expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN lease_owner,
+    DROP COLUMN lease_expires_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN ttl_minutes INTEGER,
+    ADD COLUMN skip_hours INTEGER[],
+    ADD COLUMN skip_days TEXT[],
+    ADD COLUMN update_period TEXT,
+    ADD COLUMN update_frequency INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN ttl_minutes,
+    DROP COLUMN skip_hours,
+    DROP COLUMN skip_days,
+    DROP COLUMN update_period,
+    DROP COLUMN update_frequency;